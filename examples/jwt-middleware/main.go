@@ -43,7 +43,7 @@ func main() {
 					return &ExampleRequest{}
 				},
 				Handle: func(ctx context.Context, req rest.Request) rest.Response {
-					if claims, ok := ctx.Value(jwt.ContextKeyClaims).(gojwt.Claims); ok {
+					if claims, ok := jwt.ClaimsFrom[*gojwt.RegisteredClaims](ctx); ok {
 						fmt.Println("Claims:", claims)
 					}
 					r := req.(*ExampleRequest)
@@ -27,17 +27,11 @@ func main() {
 	}
 	http.Handle("/api/example", &rest.Endpoint{
 		Method: map[string]rest.Handler{
-			"POST": {
-				NewRequest: func() rest.Request {
-					return &ExampleRequest{}
-				},
-				Handle: func(ctx context.Context, req rest.Request) rest.Response {
-					r := req.(*ExampleRequest)
-					return &ExampleResponse{
-						Sum: r.A + r.B,
-					}
-				},
-			},
+			"POST": rest.Handle(func(ctx context.Context, r *ExampleRequest) (*ExampleResponse, error) {
+				return &ExampleResponse{
+					Sum: r.A + r.B,
+				}, nil
+			}),
 		},
 	})
 
@@ -0,0 +1,313 @@
+package jwt
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/smxlong/kit/rest"
+)
+
+var (
+	// ErrUnknownAPIKey is returned when an API key is not in the store.
+	ErrUnknownAPIKey = errors.New("unknown API key")
+	// ErrAPIKeyExpired is returned when an API key has expired.
+	ErrAPIKeyExpired = errors.New("API key is expired")
+)
+
+// APIKey describes the client an API key was issued to.
+type APIKey struct {
+	// ID identifies the key. Unlike the key itself, it is not secret and can
+	// be logged.
+	ID string `json:"id"`
+	// Subject is the principal the key authenticates, used as the sub claim.
+	Subject string `json:"sub,omitempty"`
+	// Scopes are the scopes the key grants, used as the scope claim.
+	Scopes []string `json:"scopes,omitempty"`
+	// ExpiresAt is the time the key expires. If nil, it does not expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Metadata is arbitrary information about the key.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// claims returns the principal for the key, in the shape of JWT claims so that
+// HasScope and the other predicates can be used with it.
+func (k *APIKey) claims() gojwt.MapClaims {
+	claims := gojwt.MapClaims{"api_key_id": k.ID}
+	if k.Subject != "" {
+		claims["sub"] = k.Subject
+	}
+	if len(k.Scopes) > 0 {
+		claims["scope"] = strings.Join(k.Scopes, " ")
+	}
+	if k.ExpiresAt != nil {
+		claims["exp"] = k.ExpiresAt.Unix()
+	}
+	return claims
+}
+
+// APIKeyStore looks up API keys.
+type APIKeyStore interface {
+	// Lookup returns the description of the given key. It returns
+	// ErrUnknownAPIKey if the key is not in the store.
+	Lookup(ctx context.Context, key string) (*APIKey, error)
+}
+
+// GenerateAPIKey returns a new random API key.
+func GenerateAPIKey() (string, error) {
+	b, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashAPIKey returns the hash of an API key, as stored by FileAPIKeyStore: the
+// hex-encoded SHA-256 digest of the key.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryAPIKeyStore is an APIKeyStore that keeps keys in memory. Only the
+// SHA-256 digests of the keys are kept, and they are compared in constant
+// time.
+type MemoryAPIKeyStore struct {
+	mu      sync.RWMutex
+	entries []apiKeyEntry
+}
+
+// apiKeyEntry is a key in a MemoryAPIKeyStore.
+type apiKeyEntry struct {
+	digest [sha256.Size]byte
+	key    *APIKey
+}
+
+// NewMemoryAPIKeyStore returns an empty MemoryAPIKeyStore.
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{}
+}
+
+// Add adds a key to the store, described by the given APIKey.
+func (s *MemoryAPIKeyStore) Add(key string, desc APIKey) {
+	s.add(sha256.Sum256([]byte(key)), &desc)
+}
+
+// Remove removes the key with the given ID from the store.
+func (s *MemoryAPIKeyStore) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := s.entries[:0]
+	for _, e := range s.entries {
+		if e.key.ID != id {
+			entries = append(entries, e)
+		}
+	}
+	s.entries = entries
+}
+
+// Lookup implements APIKeyStore. Every key in the store is compared, so the
+// time taken does not depend on which key matches.
+func (s *MemoryAPIKeyStore) Lookup(ctx context.Context, key string) (*APIKey, error) {
+	digest := sha256.Sum256([]byte(key))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found *APIKey
+	for _, e := range s.entries {
+		if subtle.ConstantTimeCompare(e.digest[:], digest[:]) == 1 {
+			found = e.key
+		}
+	}
+	if found == nil {
+		return nil, ErrUnknownAPIKey
+	}
+	desc := *found
+	return &desc, nil
+}
+
+// add adds a key by its digest.
+func (s *MemoryAPIKeyStore) add(digest [sha256.Size]byte, desc *APIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, apiKeyEntry{digest: digest, key: desc})
+}
+
+// FileAPIKeyStore is an APIKeyStore loaded from a file, so that keys can be
+// provisioned without storing them in plain text. Each line of the file is a
+// JSON object with the fields of an APIKey and a "hash" field holding the
+// HashAPIKey of the key, for example:
+//
+//	{"id":"ci","sub":"ci-bot","scopes":["deploy"],"hash":"9f86d0..."}
+type FileAPIKeyStore struct {
+	*MemoryAPIKeyStore
+	path string
+}
+
+// apiKeyRecord is a line in a FileAPIKeyStore file.
+type apiKeyRecord struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+// OpenFileAPIKeyStore returns a FileAPIKeyStore loaded from the given file.
+func OpenFileAPIKeyStore(path string) (*FileAPIKeyStore, error) {
+	s := &FileAPIKeyStore{
+		MemoryAPIKeyStore: NewMemoryAPIKeyStore(),
+		path:              path,
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload replaces the keys in the store with those in the file. If the file
+// cannot be read, the store is left unchanged.
+func (s *FileAPIKeyStore) Reload() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	store := NewMemoryAPIKeyStore()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record apiKeyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%s:%d: %w", s.path, line, err)
+		}
+		var digest [sha256.Size]byte
+		if n, err := hex.Decode(digest[:], []byte(record.Hash)); err != nil || n != sha256.Size || len(record.Hash) != 2*sha256.Size {
+			return fmt.Errorf("%s:%d: invalid hash for key %q", s.path, line, record.ID)
+		}
+		desc := record.APIKey
+		store.add(digest, &desc)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = store.entries
+	return nil
+}
+
+// APIKeyMiddleware authenticates requests with static API keys. It is a
+// sibling of Middleware: the principal of a valid key is stored in the request
+// context as gojwt.MapClaims, with the key's ID as the "api_key_id" claim, its
+// subject as "sub" and its scopes as "scope", so that Require, HasScope and
+// ClaimsFrom work the same as for JWTs. The APIKey itself is available from
+// APIKeyFrom.
+type APIKeyMiddleware struct {
+	store      APIKeyStore
+	extractors []Extractor
+	scheme     string
+	required   bool
+	now        func() time.Time
+}
+
+// APIKeyOption is an option for an APIKeyMiddleware.
+type APIKeyOption func(*APIKeyMiddleware)
+
+// WithAPIKeyExtractors sets where API keys are read from. The default is the
+// X-API-Key header; use FromQuery to also accept a query parameter.
+func WithAPIKeyExtractors(extractors ...Extractor) APIKeyOption {
+	return func(m *APIKeyMiddleware) {
+		m.extractors = extractors
+	}
+}
+
+// WithAPIKeyScheme sets the authentication scheme of the WWW-Authenticate
+// challenge sent with 401 responses. The default is "ApiKey".
+func WithAPIKeyScheme(scheme string) APIKeyOption {
+	return func(m *APIKeyMiddleware) {
+		m.scheme = scheme
+	}
+}
+
+// WithAPIKeyRequired sets whether a valid API key is required. If required,
+// requests without one are rejected with 401 Unauthorized and a
+// WWW-Authenticate challenge. Otherwise they are passed on without a
+// principal, so that another Middleware can authenticate them.
+func WithAPIKeyRequired(required bool) APIKeyOption {
+	return func(m *APIKeyMiddleware) {
+		m.required = required
+	}
+}
+
+// NewAPIKeyMiddleware returns an APIKeyMiddleware that looks keys up in the
+// given store.
+func NewAPIKeyMiddleware(store APIKeyStore, opts ...APIKeyOption) *APIKeyMiddleware {
+	m := &APIKeyMiddleware{
+		store:      store,
+		extractors: []Extractor{FromHeader("X-API-Key", "")},
+		scheme:     "ApiKey",
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Wrap wraps an http.Handler with the API key middleware.
+func (m *APIKeyMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := m.authenticate(r)
+		if err != nil {
+			if m.required {
+				if errors.Is(err, ErrConflictingTokens) {
+					rest.WriteError(w, rest.ErrBadRequest.WithCause(err))
+				} else {
+					w.Header().Set("WWW-Authenticate", m.scheme)
+					rest.WriteError(w, rest.ErrUnauthorized.WithCause(err))
+				}
+				return
+			}
+		} else {
+			ctx := context.WithValue(r.Context(), ContextKeyClaims, key.claims())
+			ctx = context.WithValue(ctx, ContextKeyAPIKey, key)
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the description of the request's API key.
+func (m *APIKeyMiddleware) authenticate(r *http.Request) (*APIKey, error) {
+	token, err := extract(r, m.extractors)
+	if err != nil {
+		return nil, err
+	}
+	key, err := m.store.Lookup(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	if key.ExpiresAt != nil && !m.now().Before(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+	return key, nil
+}
+
+// APIKeyFrom returns the API key description stored in the context by
+// APIKeyMiddleware.
+func APIKeyFrom(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(ContextKeyAPIKey).(*APIKey)
+	return key, ok
+}
@@ -0,0 +1,140 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_MemoryAPIKeyStore_Looks_Up_Keys(t *testing.T) {
+	s := NewMemoryAPIKeyStore()
+	s.Add("secret-1", APIKey{ID: "one", Subject: "svc-a", Metadata: map[string]string{"team": "a"}})
+	s.Add("secret-2", APIKey{ID: "two", Subject: "svc-b"})
+
+	key, err := s.Lookup(context.Background(), "secret-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "one", key.ID)
+	assert.Equal(t, "a", key.Metadata["team"])
+
+	_, err = s.Lookup(context.Background(), "secret-3")
+	assert.ErrorIs(t, err, ErrUnknownAPIKey)
+
+	s.Remove("one")
+	_, err = s.Lookup(context.Background(), "secret-1")
+	assert.ErrorIs(t, err, ErrUnknownAPIKey)
+	key, err = s.Lookup(context.Background(), "secret-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "two", key.ID)
+}
+
+func Test_That_FileAPIKeyStore_Loads_Hashed_Keys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.jsonl")
+	data := `{"id":"ci","sub":"ci-bot","scopes":["deploy"],"hash":"` + HashAPIKey("ci-secret") + `"}` + "\n\n" +
+		`{"id":"ops","sub":"ops","hash":"` + HashAPIKey("ops-secret") + `"}` + "\n"
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	s, err := OpenFileAPIKeyStore(path)
+	assert.NoError(t, err)
+	key, err := s.Lookup(context.Background(), "ci-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, &APIKey{ID: "ci", Subject: "ci-bot", Scopes: []string{"deploy"}}, key)
+	_, err = s.Lookup(context.Background(), HashAPIKey("ci-secret"))
+	assert.ErrorIs(t, err, ErrUnknownAPIKey)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"id":"ops","hash":"`+HashAPIKey("ops-secret")+`"}`), 0o600))
+	assert.NoError(t, s.Reload())
+	_, err = s.Lookup(context.Background(), "ci-secret")
+	assert.ErrorIs(t, err, ErrUnknownAPIKey)
+	_, err = s.Lookup(context.Background(), "ops-secret")
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"id":"bad","hash":"abc"}`), 0o600))
+	assert.Error(t, s.Reload())
+	_, err = s.Lookup(context.Background(), "ops-secret")
+	assert.NoError(t, err)
+}
+
+func Test_That_APIKeyMiddleware_Stores_The_Principal_In_The_Context(t *testing.T) {
+	s := NewMemoryAPIKeyStore()
+	s.Add("secret", APIKey{ID: "ci", Subject: "ci-bot", Scopes: []string{"read", "deploy"}})
+	m := NewAPIKeyMiddleware(s,
+		WithAPIKeyExtractors(FromHeader("X-API-Key", ""), FromQuery("api_key")),
+		WithAPIKeyRequired(true),
+	)
+	var claims gojwt.MapClaims
+	var key *APIKey
+	h := m.Wrap(RequireScope("deploy")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ = ClaimsFrom[gojwt.MapClaims](r.Context())
+		key, _ = APIKeyFrom(r.Context())
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/?api_key=secret", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, gojwt.MapClaims{"api_key_id": "ci", "sub": "ci-bot", "scope": "read deploy"}, claims)
+	assert.Equal(t, "ci", key.ID)
+}
+
+func Test_That_APIKeyMiddleware_Rejects_Invalid_And_Expired_Keys(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryAPIKeyStore()
+	s.Add("old", APIKey{ID: "old", ExpiresAt: &now})
+	m := NewAPIKeyMiddleware(s, WithAPIKeyRequired(true))
+	m.now = func() time.Time { return now }
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, key := range []string{"", "unknown", "old"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, key)
+		assert.Equal(t, "ApiKey", w.Header().Get("WWW-Authenticate"), key)
+	}
+}
+
+func Test_That_APIKeyMiddleware_Challenges_With_The_Configured_Scheme(t *testing.T) {
+	m := NewAPIKeyMiddleware(NewMemoryAPIKeyStore(), WithAPIKeyRequired(true), WithAPIKeyScheme("Key"))
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Key", w.Header().Get("WWW-Authenticate"))
+}
+
+func Test_That_APIKey_Omits_A_Nil_Expiry_From_JSON(t *testing.T) {
+	data, err := json.Marshal(APIKey{ID: "ci"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"ci"}`, string(data))
+
+	expiresAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, err = json.Marshal(APIKey{ID: "ci", ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"ci","expires_at":"2025-01-01T00:00:00Z"}`, string(data))
+}
+
+func Test_That_An_Optional_APIKeyMiddleware_Passes_Requests_On(t *testing.T) {
+	m := NewAPIKeyMiddleware(NewMemoryAPIKeyStore())
+	called := false
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		_, ok := APIKeyFrom(r.Context())
+		assert.False(t, ok)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-API-Key", "unknown")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, called)
+}
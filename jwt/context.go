@@ -1,9 +1,66 @@
 package jwt
 
+import (
+	"context"
+	"fmt"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
 // ContextKey is a type for context keys.
 type ContextKey string
 
 const (
 	// ContextKeyClaims is the context key for claims.
 	ContextKeyClaims ContextKey = "jwtClaims"
+	// ContextKeyToken is the context key for the raw token string.
+	ContextKeyToken ContextKey = "jwtToken"
+	// ContextKeyHeader is the context key for the parsed token header.
+	ContextKeyHeader ContextKey = "jwtHeader"
+	// ContextKeyTenant is the context key for the tenant ID.
+	ContextKeyTenant ContextKey = "jwtTenant"
+	// ContextKeyAPIKey is the context key for the API key description.
+	ContextKeyAPIKey ContextKey = "jwtAPIKey"
+	// ContextKeyClientCertificate is the context key for the client
+	// certificate identity.
+	ContextKeyClientCertificate ContextKey = "jwtClientCertificate"
 )
+
+// ClaimsFrom returns the claims stored in the context by Middleware, if they
+// are of type T. T is usually the pointer type returned by the function passed
+// to WithNewClaims or NewMiddlewareWithClaims, such as
+// *gojwt.RegisteredClaims.
+func ClaimsFrom[T gojwt.Claims](ctx context.Context) (T, bool) {
+	claims, ok := ctx.Value(ContextKeyClaims).(T)
+	return claims, ok
+}
+
+// MustClaims is like ClaimsFrom, but panics if the context has no claims of
+// type T. It is intended for handlers that are only reachable through a
+// Middleware created WithRequired.
+func MustClaims[T gojwt.Claims](ctx context.Context) T {
+	claims, ok := ClaimsFrom[T](ctx)
+	if !ok {
+		panic(fmt.Sprintf("jwt: context has no claims of type %T", claims))
+	}
+	return claims
+}
+
+// ContextWithClaims returns a copy of the context with the given claims, as
+// stored by Middleware. This is useful for testing handlers.
+func ContextWithClaims(ctx context.Context, claims gojwt.Claims) context.Context {
+	return context.WithValue(ctx, ContextKeyClaims, claims)
+}
+
+// TokenFrom returns the raw token string stored in the context by Middleware.
+func TokenFrom(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(ContextKeyToken).(string)
+	return token, ok
+}
+
+// HeaderFrom returns the parsed token header stored in the context by
+// Middleware.
+func HeaderFrom(ctx context.Context) (map[string]interface{}, bool) {
+	header, ok := ctx.Value(ContextKeyHeader).(map[string]interface{})
+	return header, ok
+}
@@ -0,0 +1,67 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// testClaims are custom claims for testing.
+type testClaims struct {
+	gojwt.RegisteredClaims
+	Name string `json:"name"`
+}
+
+func Test_That_ClaimsFrom_Returns_Claims_Of_The_Given_Type(t *testing.T) {
+	ctx := ContextWithClaims(context.Background(), &testClaims{Name: "test"})
+
+	claims, ok := ClaimsFrom[*testClaims](ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "test", claims.Name)
+
+	_, ok = ClaimsFrom[*gojwt.RegisteredClaims](ctx)
+	assert.False(t, ok)
+
+	_, ok = ClaimsFrom[*testClaims](context.Background())
+	assert.False(t, ok)
+}
+
+func Test_That_MustClaims_Panics_Without_Claims(t *testing.T) {
+	assert.Panics(t, func() { MustClaims[*testClaims](context.Background()) })
+	assert.NotPanics(t, func() {
+		MustClaims[*testClaims](ContextWithClaims(context.Background(), &testClaims{}))
+	})
+}
+
+func Test_That_NewMiddlewareWithClaims_Stores_Typed_Claims_Token_And_Header(t *testing.T) {
+	tok := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &testClaims{Name: "test"})
+	tok.Header["kid"] = "key"
+	token, err := tok.SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	middleware := NewMiddlewareWithClaims(
+		func() *testClaims { return &testClaims{} },
+		WithKey([]byte("secret")),
+	)
+
+	var called bool
+	handler := middleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.Equal(t, "test", MustClaims[*testClaims](r.Context()).Name)
+		raw, ok := TokenFrom(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, token, raw)
+		header, ok := HeaderFrom(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "key", header["kid"])
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.True(t, called)
+}
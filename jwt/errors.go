@@ -0,0 +1,79 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/smxlong/kit/rest"
+)
+
+var (
+	// ErrNoToken is returned when the request does not carry a token.
+	ErrNoToken = errors.New("no token")
+	// ErrUnavailable is wrapped by errors from the services the middleware
+	// depends on to validate a token, such as a key set, OpenID Connect
+	// discovery, an introspection endpoint or a revocation store. They say
+	// nothing about the token, so the request is failed with a server error.
+	ErrUnavailable = errors.New("token validation is unavailable")
+)
+
+// describe returns the RFC 6750 error code and a human-readable description
+// for an error returned while authenticating a request. A missing token has no
+// error code, as required by RFC 6750 section 3.1.
+func describe(err error) (string, string) {
+	switch {
+	case errors.Is(err, ErrNoToken):
+		return "", ""
+	case errors.Is(err, ErrConflictingTokens):
+		return "invalid_request", "request has conflicting tokens"
+	case errors.Is(err, gojwt.ErrTokenMalformed):
+		return "invalid_token", "token is malformed"
+	case errors.Is(err, gojwt.ErrTokenExpired):
+		return "invalid_token", "token is expired"
+	case errors.Is(err, gojwt.ErrTokenNotValidYet):
+		return "invalid_token", "token is not valid yet"
+	case errors.Is(err, gojwt.ErrTokenInvalidAudience):
+		return "invalid_token", "token has invalid audience"
+	case errors.Is(err, gojwt.ErrTokenInvalidIssuer):
+		return "invalid_token", "token has invalid issuer"
+	case errors.Is(err, gojwt.ErrTokenSignatureInvalid):
+		return "invalid_token", "token signature is invalid"
+	case errors.Is(err, ErrTokenRevoked):
+		return "invalid_token", "token has been revoked"
+	case errors.Is(err, ErrTokenInactive):
+		return "invalid_token", "token is not active"
+	case errors.Is(err, ErrDecryptionFailed):
+		return "invalid_token", "token could not be decrypted"
+	case errors.Is(err, ErrCertificateMismatch):
+		return "invalid_token", "token is not bound to the client certificate"
+	case errors.Is(err, gojwt.ErrTokenRequiredClaimMissing):
+		return "invalid_token", "token is missing required claim"
+	}
+	return "invalid_token", "token is invalid"
+}
+
+// unauthorized writes a 401 response for the given authentication error, or a
+// 400 response if the request itself is invalid. The WWW-Authenticate header
+// follows RFC 6750, and the body is a rest error response. If the token could
+// not be validated because of ErrUnavailable, a 500 response is written
+// without a challenge, so that clients do not discard a valid token.
+func unauthorized(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrUnavailable) {
+		rest.WriteError(w, rest.ErrInternal.WithCause(err))
+		return
+	}
+	challenge := "Bearer"
+	code, description := describe(err)
+	if code != "" {
+		challenge = fmt.Sprintf("Bearer error=%q, error_description=%q", code, description)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	if code == "invalid_request" {
+		rest.WriteError(w, rest.ErrBadRequest.WithCause(err))
+		return
+	}
+	rest.WriteError(w, rest.ErrUnauthorized.WithCause(err))
+}
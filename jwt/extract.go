@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrConflictingTokens is returned when a request carries different tokens
+	// in more than one place.
+	ErrConflictingTokens = errors.New("conflicting tokens")
+)
+
+// Extractor extracts a token from a request. It returns ErrNoToken if the
+// request carries no token where the extractor looks.
+type Extractor func(*http.Request) (string, error)
+
+// WithExtractors sets the extractors used to find the token in a request. The
+// extractors are tried in order and the first token found is used, but a
+// request that carries different tokens in more than one place is rejected.
+// The default is FromHeader("Authorization", "Bearer").
+func WithExtractors(extractors ...Extractor) Option {
+	return func(m *Middleware) {
+		m.extractors = extractors
+	}
+}
+
+// FromHeader returns an Extractor that reads the token from the named header.
+// If scheme is not empty, the header value must be the scheme, matched without
+// regard to case, followed by a space and the token.
+func FromHeader(name, scheme string) Extractor {
+	return func(r *http.Request) (string, error) {
+		value := r.Header.Get(name)
+		if scheme != "" {
+			prefix, token, ok := strings.Cut(value, " ")
+			if !ok || !strings.EqualFold(prefix, scheme) {
+				return "", ErrNoToken
+			}
+			value = token
+		}
+		if value = strings.TrimSpace(value); value == "" {
+			return "", ErrNoToken
+		}
+		return value, nil
+	}
+}
+
+// FromCookie returns an Extractor that reads the token from the named cookie.
+func FromCookie(name string) Extractor {
+	return func(r *http.Request) (string, error) {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", ErrNoToken
+		}
+		return cookie.Value, nil
+	}
+}
+
+// FromQuery returns an Extractor that reads the token from the named URL
+// query parameter.
+func FromQuery(param string) Extractor {
+	return func(r *http.Request) (string, error) {
+		if r.URL == nil {
+			return "", ErrNoToken
+		}
+		if value := r.URL.Query().Get(param); value != "" {
+			return value, nil
+		}
+		return "", ErrNoToken
+	}
+}
+
+// FromForm returns an Extractor that reads the token from the named field of
+// a form-encoded request body.
+func FromForm(field string) Extractor {
+	return func(r *http.Request) (string, error) {
+		if r.Body == nil {
+			return "", ErrNoToken
+		}
+		if value := r.PostFormValue(field); value != "" {
+			return value, nil
+		}
+		return "", ErrNoToken
+	}
+}
+
+// extract runs the extractors in order and returns the first token found. It
+// returns ErrConflictingTokens if the extractors find different tokens.
+func extract(r *http.Request, extractors []Extractor) (string, error) {
+	var token string
+	for _, extractor := range extractors {
+		t, err := extractor(r)
+		if errors.Is(err, ErrNoToken) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if token == "" {
+			token = t
+		} else if t != token {
+			return "", ErrConflictingTokens
+		}
+	}
+	if token == "" {
+		return "", ErrNoToken
+	}
+	return token, nil
+}
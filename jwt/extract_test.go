@@ -0,0 +1,111 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_FromHeader_Matches_The_Scheme_Case_Insensitively(t *testing.T) {
+	extractor := FromHeader("Authorization", "Bearer")
+	for _, value := range []string{"Bearer abc", "bearer abc", "BEARER abc"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", value)
+		token, err := extractor(req)
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", token)
+	}
+	for _, value := range []string{"", "Bearer", "Bearer ", "Basic abc", "Bearerabc"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", value)
+		_, err := extractor(req)
+		assert.ErrorIs(t, err, ErrNoToken, value)
+	}
+}
+
+func Test_That_FromHeader_Without_A_Scheme_Returns_The_Whole_Value(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Token", "abc")
+	token, err := FromHeader("X-Token", "")(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", token)
+}
+
+func Test_That_FromCookie_Reads_The_Named_Cookie(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	token, err := FromCookie("session")(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", token)
+	_, err = FromCookie("other")(req)
+	assert.ErrorIs(t, err, ErrNoToken)
+}
+
+func Test_That_FromQuery_Reads_The_Named_Parameter(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?access_token=abc", nil)
+	token, err := FromQuery("access_token")(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", token)
+	_, err = FromQuery("other")(req)
+	assert.ErrorIs(t, err, ErrNoToken)
+}
+
+func Test_That_FromForm_Reads_The_Named_Field(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(url.Values{"access_token": {"abc"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	token, err := FromForm("access_token")(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", token)
+	_, err = FromForm("other")(req)
+	assert.ErrorIs(t, err, ErrNoToken)
+}
+
+func Test_That_WithExtractors_Tries_The_Extractors_In_Order(t *testing.T) {
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{Subject: "test"}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	middleware := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithExtractors(
+			FromHeader("Authorization", "Bearer"),
+			FromCookie("token"),
+			FromQuery("access_token"),
+		),
+	)
+
+	req := httptest.NewRequest("GET", "/?access_token="+token, nil)
+	got, err := middleware.getToken(req)
+	assert.NoError(t, err)
+	assert.Equal(t, token, got)
+
+	// The same token in more than one place is fine.
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	got, err = middleware.getToken(req)
+	assert.NoError(t, err)
+	assert.Equal(t, token, got)
+}
+
+func Test_That_WithExtractors_Rejects_Conflicting_Tokens(t *testing.T) {
+	middleware := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithExtractors(FromHeader("Authorization", "Bearer"), FromQuery("access_token")),
+		WithRequired(true),
+	)
+
+	handler := middleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+
+	req := httptest.NewRequest("GET", "/?access_token=abc", nil)
+	req.Header.Set("Authorization", "Bearer def")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `Bearer error="invalid_request", error_description="request has conflicting tokens"`, rec.Header().Get("WWW-Authenticate"))
+}
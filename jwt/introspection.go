@@ -0,0 +1,255 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrTokenInactive is returned when token introspection reports that a
+	// token is not active.
+	ErrTokenInactive = errors.New("token is not active")
+)
+
+// Introspector validates opaque tokens with an OAuth 2.0 token introspection
+// endpoint, as described in RFC 7662. Results for active tokens are cached
+// until the TTL or the token's exp claim; results for inactive tokens are
+// cached for a shorter TTL, so that a revoked token is soon rejected as
+// inactive again. Both share a cache of bounded size.
+type Introspector struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+	cacheTTL     time.Duration
+	negativeTTL  time.Duration
+	cacheSize    int
+	now          func() time.Time
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]introspectionResult
+}
+
+// introspectionResult is a cached introspection result. Its claims are nil if
+// the token is not active.
+type introspectionResult struct {
+	claims  gojwt.MapClaims
+	expires time.Time
+}
+
+// IntrospectorOption is an option for an Introspector.
+type IntrospectorOption func(*Introspector)
+
+// WithIntrospectionClientCredentials sets the client credentials used to
+// authenticate to the introspection endpoint with HTTP Basic authentication.
+func WithIntrospectionClientCredentials(clientID, clientSecret string) IntrospectorOption {
+	return func(i *Introspector) {
+		i.clientID = clientID
+		i.clientSecret = clientSecret
+	}
+}
+
+// WithIntrospectionHTTPClient sets the HTTP client used to call the
+// introspection endpoint. The default is a client with a ten second timeout.
+func WithIntrospectionHTTPClient(client *http.Client) IntrospectorOption {
+	return func(i *Introspector) {
+		i.client = client
+	}
+}
+
+// WithIntrospectionCacheTTL sets how long results for active tokens are cached.
+// They are never cached past the token's exp claim. The default is five
+// minutes. A TTL of zero disables caching of active tokens.
+func WithIntrospectionCacheTTL(ttl time.Duration) IntrospectorOption {
+	return func(i *Introspector) {
+		i.cacheTTL = ttl
+	}
+}
+
+// WithIntrospectionNegativeCacheTTL sets how long results for inactive tokens
+// are cached. The default is ten seconds. A TTL of zero disables caching of
+// inactive tokens.
+func WithIntrospectionNegativeCacheTTL(ttl time.Duration) IntrospectorOption {
+	return func(i *Introspector) {
+		i.negativeTTL = ttl
+	}
+}
+
+// WithIntrospectionCacheSize sets the maximum number of cached results. When
+// the cache is full, a cached result is evicted at random to make room. The
+// default is 10000.
+func WithIntrospectionCacheSize(size int) IntrospectorOption {
+	return func(i *Introspector) {
+		i.cacheSize = size
+	}
+}
+
+// NewIntrospector returns an Introspector that calls the given introspection
+// endpoint.
+func NewIntrospector(endpoint string, opts ...IntrospectorOption) *Introspector {
+	i := &Introspector{
+		endpoint:    endpoint,
+		client:      defaultHTTPClient,
+		cacheTTL:    5 * time.Minute,
+		negativeTTL: 10 * time.Second,
+		cacheSize:   10000,
+		now:         time.Now,
+		cache:       map[[sha256.Size]byte]introspectionResult{},
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// WithIntrospection makes the middleware accept opaque tokens as well as JWTs.
+// Tokens that are not JWTs are validated with the Introspector, and the
+// introspection response, without its "active" member, is stored as the
+// claims. The audiences, issuers, required claims and revocation set on the
+// middleware also apply to these claims.
+func WithIntrospection(introspector *Introspector) Option {
+	return func(m *Middleware) {
+		m.introspector = introspector
+	}
+}
+
+// Introspect returns the claims of an active token. It returns
+// ErrTokenInactive if the token is not active. The endpoint is called with a
+// ten second timeout.
+func (i *Introspector) Introspect(ctx context.Context, token string) (gojwt.MapClaims, error) {
+	key := sha256.Sum256([]byte(token))
+	now := i.now()
+	if claims, ok := i.lookup(key, now); ok {
+		if claims == nil {
+			return nil, ErrTokenInactive
+		}
+		return claims, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	claims, err := i.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		i.store(key, introspectionResult{expires: now.Add(i.negativeTTL)}, i.negativeTTL)
+		return nil, ErrTokenInactive
+	}
+	result := introspectionResult{claims: claims, expires: now.Add(i.cacheTTL)}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil && exp.Before(result.expires) {
+		result.expires = exp.Time
+	}
+	i.store(key, result, i.cacheTTL)
+	return claims, nil
+}
+
+// lookup returns the cached claims for a token, forgetting them if they have
+// expired. The claims are nil if the token is cached as inactive.
+func (i *Introspector) lookup(key [sha256.Size]byte, now time.Time) (gojwt.MapClaims, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	result, ok := i.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(result.expires) {
+		delete(i.cache, key)
+		return nil, false
+	}
+	return result.claims, true
+}
+
+// introspect calls the introspection endpoint. It returns nil claims if the
+// token is not active.
+func (i *Introspector) introspect(ctx context.Context, token string) (gojwt.MapClaims, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("POST %s: unexpected status %s", i.endpoint, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	claims := gojwt.MapClaims{}
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, fmt.Errorf("invalid introspection response: %w", err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, nil
+	}
+	delete(claims, "active")
+	return claims, nil
+}
+
+// store caches a result unless its TTL is zero, evicting another result if the
+// cache is full.
+func (i *Introspector) store(key [sha256.Size]byte, result introspectionResult, ttl time.Duration) {
+	if ttl <= 0 || i.cacheSize <= 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.cache[key]; !ok && len(i.cache) >= i.cacheSize {
+		for k := range i.cache {
+			delete(i.cache, k)
+			break
+		}
+	}
+	i.cache[key] = result
+}
+
+// introspect validates an opaque token with the middleware's Introspector.
+func (m *Middleware) introspect(ctx context.Context, token string) (*gojwt.Token, error) {
+	claims, err := m.introspector.Introspect(ctx, token)
+	if errors.Is(err, ErrTokenInactive) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	v := &verification{
+		audiences: m.acceptedAudiences(),
+		issuers:   m.acceptedIssuers(),
+	}
+	if err := gojwt.NewValidator(m.parserOptions(v)...).Validate(claims); err != nil {
+		return nil, err
+	}
+	if err := m.checkClaims(ctx, claims, v); err != nil {
+		return nil, err
+	}
+	return &gojwt.Token{
+		Raw:    token,
+		Header: map[string]interface{}{},
+		Claims: claims,
+		Valid:  true,
+	}, nil
+}
+
+// isJWT returns true if the token looks like a JWT in compact serialization.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
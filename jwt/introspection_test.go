@@ -0,0 +1,212 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestIntrospectionServer starts an introspection endpoint that reports the
+// given responses for the tokens they are keyed by, and inactive for any other
+// token. It counts the requests it receives.
+func newTestIntrospectionServer(t *testing.T, responses map[string]map[string]interface{}) (*httptest.Server, *int) {
+	t.Helper()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.PostFormValue("token_type_hint") != "access_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp, ok := responses[r.PostFormValue("token")]
+		if !ok {
+			resp = map[string]interface{}{"active": false}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func Test_That_Introspector_Returns_The_Claims_Of_An_Active_Token(t *testing.T) {
+	server, _ := newTestIntrospectionServer(t, map[string]map[string]interface{}{
+		"opaque": {"active": true, "sub": "jdoe", "scope": "read write"},
+	})
+	i := NewIntrospector(server.URL, WithIntrospectionClientCredentials("client", "s3cret"))
+
+	claims, err := i.Introspect(context.Background(), "opaque")
+	assert.NoError(t, err)
+	assert.Equal(t, gojwt.MapClaims{"sub": "jdoe", "scope": "read write"}, claims)
+
+	_, err = i.Introspect(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrTokenInactive)
+}
+
+func Test_That_Introspector_Fails_When_The_Endpoint_Rejects_The_Request(t *testing.T) {
+	server, _ := newTestIntrospectionServer(t, nil)
+	i := NewIntrospector(server.URL, WithIntrospectionClientCredentials("client", "wrong"))
+
+	_, err := i.Introspect(context.Background(), "opaque")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrTokenInactive)
+}
+
+func Test_That_Introspector_Caches_Results_Until_The_TTL_Or_Exp(t *testing.T) {
+	now := time.Now()
+	server, calls := newTestIntrospectionServer(t, map[string]map[string]interface{}{
+		"long":  {"active": true, "sub": "a"},
+		"short": {"active": true, "sub": "b", "exp": now.Add(time.Minute).Unix()},
+	})
+	i := NewIntrospector(server.URL,
+		WithIntrospectionClientCredentials("client", "s3cret"),
+		WithIntrospectionCacheTTL(10*time.Minute),
+	)
+	i.now = func() time.Time { return now }
+
+	for _, token := range []string{"long", "short", "long", "short"} {
+		_, _ = i.Introspect(context.Background(), token)
+	}
+	assert.Equal(t, 2, *calls)
+
+	now = now.Add(2 * time.Minute)
+	_, _ = i.Introspect(context.Background(), "long")
+	_, _ = i.Introspect(context.Background(), "short")
+	assert.Equal(t, 3, *calls)
+
+	now = now.Add(10 * time.Minute)
+	_, _ = i.Introspect(context.Background(), "long")
+	assert.Equal(t, 4, *calls)
+}
+
+func Test_That_Introspector_Caches_Inactive_Tokens_For_The_Negative_TTL(t *testing.T) {
+	now := time.Now()
+	server, calls := newTestIntrospectionServer(t, nil)
+	i := NewIntrospector(server.URL,
+		WithIntrospectionClientCredentials("client", "s3cret"),
+		WithIntrospectionNegativeCacheTTL(30*time.Second),
+	)
+	i.now = func() time.Time { return now }
+
+	for range 2 {
+		_, err := i.Introspect(context.Background(), "inactive")
+		assert.ErrorIs(t, err, ErrTokenInactive)
+	}
+	assert.Equal(t, 1, *calls)
+
+	now = now.Add(time.Minute)
+	_, err := i.Introspect(context.Background(), "inactive")
+	assert.ErrorIs(t, err, ErrTokenInactive)
+	assert.Equal(t, 2, *calls)
+}
+
+func Test_That_Introspector_Does_Not_Cache_Inactive_Tokens_With_A_Zero_Negative_TTL(t *testing.T) {
+	server, calls := newTestIntrospectionServer(t, nil)
+	i := NewIntrospector(server.URL,
+		WithIntrospectionClientCredentials("client", "s3cret"),
+		WithIntrospectionNegativeCacheTTL(0),
+	)
+
+	for range 2 {
+		_, err := i.Introspect(context.Background(), "inactive")
+		assert.ErrorIs(t, err, ErrTokenInactive)
+	}
+	assert.Equal(t, 2, *calls)
+	assert.Empty(t, i.cache)
+}
+
+func Test_That_Introspector_Limits_The_Cache_Size_For_Inactive_Tokens(t *testing.T) {
+	server, _ := newTestIntrospectionServer(t, nil)
+	i := NewIntrospector(server.URL,
+		WithIntrospectionClientCredentials("client", "s3cret"),
+		WithIntrospectionCacheSize(2),
+	)
+
+	for _, token := range []string{"a", "b", "c", "d"} {
+		_, err := i.Introspect(context.Background(), token)
+		assert.ErrorIs(t, err, ErrTokenInactive)
+	}
+	assert.Len(t, i.cache, 2)
+}
+
+func Test_That_Introspector_Limits_The_Cache_Size(t *testing.T) {
+	responses := map[string]map[string]interface{}{}
+	for _, token := range []string{"a", "b", "c", "d"} {
+		responses[token] = map[string]interface{}{"active": true, "sub": token}
+	}
+	server, calls := newTestIntrospectionServer(t, responses)
+	i := NewIntrospector(server.URL,
+		WithIntrospectionClientCredentials("client", "s3cret"),
+		WithIntrospectionCacheSize(2),
+	)
+
+	for _, token := range []string{"a", "b", "c", "d"} {
+		_, err := i.Introspect(context.Background(), token)
+		assert.NoError(t, err)
+	}
+	assert.Len(t, i.cache, 2)
+	_, err := i.Introspect(context.Background(), "d")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, *calls)
+}
+
+func Test_That_WithIntrospection_Accepts_Opaque_Tokens_And_JWTs(t *testing.T) {
+	server, _ := newTestIntrospectionServer(t, map[string]map[string]interface{}{
+		"opaque":  {"active": true, "sub": "jdoe", "aud": "api"},
+		"foreign": {"active": true, "sub": "jdoe", "aud": "other"},
+	})
+	secret := []byte("secret")
+	m := NewMiddleware(
+		WithKey(secret),
+		WithAudience("api"),
+		WithIntrospection(NewIntrospector(server.URL, WithIntrospectionClientCredentials("client", "s3cret"))),
+	)
+
+	claims, err := m.parseClaims("opaque")
+	assert.NoError(t, err)
+	sub, err := claims.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "jdoe", sub)
+
+	_, err = m.parseClaims("foreign")
+	assert.ErrorIs(t, err, gojwt.ErrTokenInvalidAudience)
+
+	_, err = m.parseClaims("unknown")
+	assert.ErrorIs(t, err, ErrTokenInactive)
+
+	tok := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{Subject: "jwt", Audience: gojwt.ClaimStrings{"api"}})
+	signed, err := tok.SignedString(secret)
+	assert.NoError(t, err)
+	claims, err = m.parseClaims(signed)
+	assert.NoError(t, err)
+	sub, err = claims.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt", sub)
+}
+
+func Test_That_Middleware_Rejects_An_Inactive_Opaque_Token(t *testing.T) {
+	server, _ := newTestIntrospectionServer(t, nil)
+	m := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithRequired(true),
+		WithIntrospection(NewIntrospector(server.URL, WithIntrospectionClientCredentials("client", "s3cret"))),
+	)
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer opaque")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer error="invalid_token", error_description="token is not active"`, w.Header().Get("WWW-Authenticate"))
+}
@@ -0,0 +1,303 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrDecryptionFailed is returned when an encrypted token cannot be
+	// decrypted, either because it was not encrypted for the key or because it
+	// has been tampered with.
+	ErrDecryptionFailed = errors.New("token decryption failed")
+)
+
+// jweHeader is the protected header of a JWE.
+type jweHeader struct {
+	Algorithm          string `json:"alg"`
+	Encryption         string `json:"enc"`
+	ContentType        string `json:"cty,omitempty"`
+	EphemeralPublicKey *JWK   `json:"epk,omitempty"`
+	PartyUInfo         string `json:"apu,omitempty"`
+	PartyVInfo         string `json:"apv,omitempty"`
+	Compression        string `json:"zip,omitempty"`
+}
+
+// EncryptToken encrypts a token as a JWE in compact serialization, as
+// described in RFC 7516, so that it can only be read by the holder of the
+// recipient's private key. The JWE's cty header is "JWT".
+//
+// The key management algorithm alg is "RSA-OAEP" or "RSA-OAEP-256", for which
+// key must be an *rsa.PublicKey; "ECDH-ES", for which key must be an
+// *ecdsa.PublicKey; or "dir", for which key must be a []byte shared secret of
+// the size required by enc. The content encryption algorithm enc is "A128GCM",
+// "A192GCM" or "A256GCM".
+func EncryptToken(token string, key interface{}, alg, enc string) (string, error) {
+	size, err := contentKeySize(enc)
+	if err != nil {
+		return "", err
+	}
+	header := jweHeader{Algorithm: alg, Encryption: enc, ContentType: "JWT"}
+	var cek, encryptedKey []byte
+	switch alg {
+	case "dir":
+		secret, ok := key.([]byte)
+		if !ok {
+			return "", fmt.Errorf("key of type %T cannot be used with %s", key, alg)
+		}
+		if len(secret) != size {
+			return "", fmt.Errorf("%s requires a %d-byte key", enc, size)
+		}
+		cek = secret
+	case "RSA-OAEP", "RSA-OAEP-256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return "", fmt.Errorf("key of type %T cannot be used with %s", key, alg)
+		}
+		if cek, err = randomBytes(size); err != nil {
+			return "", err
+		}
+		if encryptedKey, err = rsa.EncryptOAEP(oaepHash(alg), rand.Reader, pub, cek, nil); err != nil {
+			return "", err
+		}
+	case "ECDH-ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return "", fmt.Errorf("key of type %T cannot be used with %s", key, alg)
+		}
+		ephemeral, err := ecdsa.GenerateKey(pub.Curve, rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		if header.EphemeralPublicKey, err = NewJWK(&ephemeral.PublicKey); err != nil {
+			return "", err
+		}
+		if cek, err = agreeKey(ephemeral, pub, enc, nil, nil, size); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported key management algorithm %q", alg)
+	}
+
+	data, err := json.Marshal(&header)
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(data)
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv, err := randomBytes(gcm.NonceSize())
+	if err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, []byte(token), []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	enc64 := base64.RawURLEncoding.EncodeToString
+	return strings.Join([]string{protected, enc64(encryptedKey), enc64(iv), enc64(ciphertext), enc64(tag)}, "."), nil
+}
+
+// DecryptToken decrypts a JWE in compact serialization, and returns the token
+// it contains. The key is the recipient's private key: an *rsa.PrivateKey for
+// "RSA-OAEP" and "RSA-OAEP-256", an *ecdsa.PrivateKey for "ECDH-ES", or a
+// []byte shared secret for "dir". See EncryptToken.
+func DecryptToken(token string, key interface{}) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return "", fmt.Errorf("%w: JWE must have 5 segments", gojwt.ErrTokenMalformed)
+	}
+	segments := make([][]byte, len(parts))
+	for i, part := range parts {
+		var err error
+		if segments[i], err = base64.RawURLEncoding.DecodeString(part); err != nil {
+			return "", fmt.Errorf("%w: %w", gojwt.ErrTokenMalformed, err)
+		}
+	}
+	var header jweHeader
+	if err := json.Unmarshal(segments[0], &header); err != nil {
+		return "", fmt.Errorf("%w: %w", gojwt.ErrTokenMalformed, err)
+	}
+	if header.Compression != "" {
+		return "", fmt.Errorf("%w: unsupported compression %q", gojwt.ErrTokenUnverifiable, header.Compression)
+	}
+	size, err := contentKeySize(header.Encryption)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", gojwt.ErrTokenUnverifiable, err)
+	}
+	cek, err := contentKey(&header, segments[1], key, size)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	if len(segments[2]) != gcm.NonceSize() || len(segments[4]) != gcm.Overhead() {
+		return "", fmt.Errorf("%w: invalid IV or authentication tag length", gojwt.ErrTokenMalformed)
+	}
+	plaintext, err := gcm.Open(nil, segments[2], append(segments[3], segments[4]...), []byte(parts[0]))
+	if err != nil {
+		return "", ErrDecryptionFailed
+	}
+	return string(plaintext), nil
+}
+
+// WithDecryptionKey makes the middleware accept tokens encrypted as a JWE, such
+// as nested JWTs, by decrypting them with the given private key before the
+// signature of the token they contain is validated. See DecryptToken for the
+// supported keys. Tokens that are not encrypted are still accepted.
+func WithDecryptionKey(key interface{}) Option {
+	return func(m *Middleware) {
+		m.decryptionKey = key
+	}
+}
+
+// isJWE returns true if the token looks like a JWE in compact serialization.
+func isJWE(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// contentKey returns the content encryption key of a JWE.
+func contentKey(header *jweHeader, encryptedKey []byte, key interface{}, size int) ([]byte, error) {
+	var cek []byte
+	switch header.Algorithm {
+	case "dir":
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("key of type %T cannot be used with %s", key, header.Algorithm)
+		}
+		if len(encryptedKey) != 0 {
+			return nil, fmt.Errorf("%w: unexpected encrypted key", gojwt.ErrTokenMalformed)
+		}
+		cek = secret
+	case "RSA-OAEP", "RSA-OAEP-256":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("key of type %T cannot be used with %s", key, header.Algorithm)
+		}
+		var err error
+		if cek, err = rsa.DecryptOAEP(oaepHash(header.Algorithm), nil, priv, encryptedKey, nil); err != nil {
+			return nil, ErrDecryptionFailed
+		}
+	case "ECDH-ES":
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("key of type %T cannot be used with %s", key, header.Algorithm)
+		}
+		if len(encryptedKey) != 0 {
+			return nil, fmt.Errorf("%w: unexpected encrypted key", gojwt.ErrTokenMalformed)
+		}
+		if header.EphemeralPublicKey == nil || header.EphemeralPublicKey.KeyType != "EC" {
+			return nil, fmt.Errorf("%w: missing or invalid epk header", gojwt.ErrTokenMalformed)
+		}
+		epk, err := header.EphemeralPublicKey.ecdsaPublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", gojwt.ErrTokenMalformed, err)
+		}
+		apu, err := base64.RawURLEncoding.DecodeString(header.PartyUInfo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", gojwt.ErrTokenMalformed, err)
+		}
+		apv, err := base64.RawURLEncoding.DecodeString(header.PartyVInfo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", gojwt.ErrTokenMalformed, err)
+		}
+		if cek, err = agreeKey(priv, epk, header.Encryption, apu, apv, size); err != nil {
+			return nil, ErrDecryptionFailed
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported key management algorithm %q", gojwt.ErrTokenUnverifiable, header.Algorithm)
+	}
+	if len(cek) != size {
+		return nil, ErrDecryptionFailed
+	}
+	return cek, nil
+}
+
+// agreeKey derives a content encryption key of the given size with ECDH-ES,
+// using the Concat KDF described in RFC 7518, section 4.6.2.
+func agreeKey(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey, enc string, apu, apv []byte, size int) ([]byte, error) {
+	if priv.Curve != pub.Curve {
+		return nil, errors.New("ECDH keys are on different curves")
+	}
+	ecdhPriv, err := priv.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	ecdhPub, err := pub.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	z, err := ecdhPriv.ECDH(ecdhPub)
+	if err != nil {
+		return nil, err
+	}
+	return concatKDF(z, enc, apu, apv, size), nil
+}
+
+// concatKDF implements the Concat KDF of NIST SP 800-56A with SHA-256, as used
+// by ECDH-ES in direct key agreement mode.
+func concatKDF(z []byte, algorithmID string, apu, apv []byte, size int) []byte {
+	var otherInfo []byte
+	for _, field := range [][]byte{[]byte(algorithmID), apu, apv} {
+		otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(len(field)))
+		otherInfo = append(otherInfo, field...)
+	}
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(size*8))
+	var key []byte
+	h := sha256.New()
+	for counter := uint32(1); len(key) < size; counter++ {
+		h.Reset()
+		_ = binary.Write(h, binary.BigEndian, counter)
+		h.Write(z)
+		h.Write(otherInfo)
+		key = h.Sum(key)
+	}
+	return key[:size]
+}
+
+// contentKeySize returns the key size, in bytes, of a content encryption
+// algorithm.
+func contentKeySize(enc string) (int, error) {
+	switch enc {
+	case "A128GCM":
+		return 16, nil
+	case "A192GCM":
+		return 24, nil
+	case "A256GCM":
+		return 32, nil
+	}
+	return 0, fmt.Errorf("unsupported content encryption algorithm %q", enc)
+}
+
+// oaepHash returns the hash function of an RSA-OAEP algorithm.
+func oaepHash(alg string) hash.Hash {
+	if alg == "RSA-OAEP-256" {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+// newGCM returns an AES-GCM cipher with the given key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,127 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test vector from RFC 7518, appendix C.
+func Test_That_concatKDF_Derives_The_RFC_7518_Test_Vector(t *testing.T) {
+	z := []byte{158, 86, 217, 29, 129, 113, 53, 211, 114, 131, 66, 131, 191, 132,
+		38, 156, 251, 49, 110, 163, 218, 128, 106, 72, 246, 218, 167, 121, 140, 254,
+		144, 196}
+	key := concatKDF(z, "A128GCM", []byte("Alice"), []byte("Bob"), 16)
+	assert.Equal(t, "VqqN6vgjbSBcIijNcacQGg", base64.RawURLEncoding.EncodeToString(key))
+}
+
+func Test_That_EncryptToken_And_DecryptToken_Round_Trip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	secret := make([]byte, 16)
+
+	for _, tc := range []struct {
+		alg, enc string
+		pub      interface{}
+		priv     interface{}
+	}{
+		{"RSA-OAEP", "A128GCM", &rsaKey.PublicKey, rsaKey},
+		{"RSA-OAEP-256", "A256GCM", &rsaKey.PublicKey, rsaKey},
+		{"ECDH-ES", "A128GCM", &ecKey.PublicKey, ecKey},
+		{"ECDH-ES", "A256GCM", &ecKey.PublicKey, ecKey},
+		{"dir", "A128GCM", secret, secret},
+	} {
+		jwe, err := EncryptToken("a.b.c", tc.pub, tc.alg, tc.enc)
+		assert.NoError(t, err, tc.alg)
+		assert.Equal(t, 4, strings.Count(jwe, "."))
+
+		token, err := DecryptToken(jwe, tc.priv)
+		assert.NoError(t, err, tc.alg)
+		assert.Equal(t, "a.b.c", token)
+
+		parts := strings.Split(jwe, ".")
+		parts[3] = base64.RawURLEncoding.EncodeToString([]byte("tampered"))
+		_, err = DecryptToken(strings.Join(parts, "."), tc.priv)
+		assert.Error(t, err, tc.alg)
+	}
+}
+
+func Test_That_DecryptToken_Rejects_The_Wrong_Key(t *testing.T) {
+	key1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	key2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	key3, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+
+	jwe, err := EncryptToken("a.b.c", &key1.PublicKey, "ECDH-ES", "A256GCM")
+	assert.NoError(t, err)
+	_, err = DecryptToken(jwe, key2)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+	_, err = DecryptToken(jwe, key3)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+	_, err = DecryptToken(jwe, []byte("secret"))
+	assert.Error(t, err)
+}
+
+func Test_That_EncryptToken_Rejects_Unsupported_Parameters(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	_, err = EncryptToken("a.b.c", &key.PublicKey, "RSA-OAEP", "A256GCM")
+	assert.Error(t, err)
+	_, err = EncryptToken("a.b.c", &key.PublicKey, "ECDH-ES+A128KW", "A256GCM")
+	assert.Error(t, err)
+	_, err = EncryptToken("a.b.c", &key.PublicKey, "ECDH-ES", "A128CBC-HS256")
+	assert.Error(t, err)
+	_, err = EncryptToken("a.b.c", make([]byte, 16), "dir", "A256GCM")
+	assert.Error(t, err)
+}
+
+func Test_That_WithDecryptionKey_Accepts_Nested_JWTs(t *testing.T) {
+	secret := []byte("secret")
+	encKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	signed, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{Subject: "jdoe"}).SignedString(secret)
+	assert.NoError(t, err)
+	jwe, err := EncryptToken(signed, &encKey.PublicKey, "RSA-OAEP-256", "A256GCM")
+	assert.NoError(t, err)
+
+	m := NewMiddleware(WithKey(secret), WithDecryptionKey(encKey), WithRequired(true))
+	var token string
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ = TokenFrom(r.Context())
+	}))
+
+	for _, tc := range []struct {
+		token  string
+		status int
+	}{
+		{jwe, http.StatusOK},
+		{signed, http.StatusOK},
+		{jwe[:len(jwe)-4] + "AAAA", http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+tc.token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, tc.status, w.Code)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, signed, token)
+		}
+	}
+
+	_, err = NewMiddleware(WithKey(secret)).parseClaims(jwe)
+	assert.ErrorIs(t, err, gojwt.ErrTokenMalformed)
+}
@@ -0,0 +1,366 @@
+package jwt
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a JSON Web Key, as described in RFC 7517.
+type JWK struct {
+	// KeyType is the key type ("RSA", "EC", "OKP" or "oct").
+	KeyType string `json:"kty"`
+	// KeyID is the key ID.
+	KeyID string `json:"kid,omitempty"`
+	// Use is the intended use of the key ("sig" or "enc").
+	Use string `json:"use,omitempty"`
+	// Algorithm is the algorithm the key is intended for.
+	Algorithm string `json:"alg,omitempty"`
+	// N is the RSA modulus.
+	N string `json:"n,omitempty"`
+	// E is the RSA public exponent.
+	E string `json:"e,omitempty"`
+	// Curve is the curve of an EC or OKP key.
+	Curve string `json:"crv,omitempty"`
+	// X is the x coordinate of an EC key, or the public key of an OKP key.
+	X string `json:"x,omitempty"`
+	// Y is the y coordinate of an EC key.
+	Y string `json:"y,omitempty"`
+	// D is the private exponent of an RSA key, or the private key of an EC or
+	// OKP key.
+	D string `json:"d,omitempty"`
+	// P is the first prime factor of an RSA key.
+	P string `json:"p,omitempty"`
+	// Q is the second prime factor of an RSA key.
+	Q string `json:"q,omitempty"`
+	// DP is the first factor CRT exponent of an RSA key.
+	DP string `json:"dp,omitempty"`
+	// DQ is the second factor CRT exponent of an RSA key.
+	DQ string `json:"dq,omitempty"`
+	// QI is the first CRT coefficient of an RSA key.
+	QI string `json:"qi,omitempty"`
+	// K is the secret of a symmetric ("oct") key.
+	K string `json:"k,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set, as described in RFC 7517.
+type JWKS struct {
+	// Keys are the keys in the set.
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK returns a JWK for the given public key, which must be an
+// *rsa.PublicKey, an *ecdsa.PublicKey or an ed25519.PublicKey.
+func NewJWK(key interface{}) (*JWK, error) {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			KeyType: "RSA",
+			N:       enc(key.N.Bytes()),
+			E:       enc(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return &JWK{
+			KeyType: "EC",
+			Curve:   key.Curve.Params().Name,
+			X:       enc(key.X.FillBytes(make([]byte, size))),
+			Y:       enc(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return &JWK{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       enc(key),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// NewPrivateJWK returns a JWK for the given private key, which must be an
+// *rsa.PrivateKey, an *ecdsa.PrivateKey, an ed25519.PrivateKey, or a []byte
+// secret for a symmetric key. The JWK includes the private key, so it must be
+// kept secret; use NewJWK for the public key.
+func NewPrivateJWK(key interface{}) (*JWK, error) {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if len(key.Primes) != 2 {
+			return nil, errors.New("multi-prime RSA keys are not supported")
+		}
+		jwk, err := NewJWK(&key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		p, q := key.Primes[0], key.Primes[1]
+		dp, dq, qi := key.Precomputed.Dp, key.Precomputed.Dq, key.Precomputed.Qinv
+		if dp == nil {
+			// Compute the CRT values rather than calling Precompute, which
+			// would modify the caller's key.
+			one := big.NewInt(1)
+			dp = new(big.Int).Mod(key.D, new(big.Int).Sub(p, one))
+			dq = new(big.Int).Mod(key.D, new(big.Int).Sub(q, one))
+			qi = new(big.Int).ModInverse(q, p)
+		}
+		jwk.D = enc(key.D.Bytes())
+		jwk.P = enc(p.Bytes())
+		jwk.Q = enc(q.Bytes())
+		jwk.DP = enc(dp.Bytes())
+		jwk.DQ = enc(dq.Bytes())
+		jwk.QI = enc(qi.Bytes())
+		return jwk, nil
+	case *ecdsa.PrivateKey:
+		jwk, err := NewJWK(&key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		jwk.D = enc(key.D.FillBytes(make([]byte, (key.Curve.Params().BitSize+7)/8)))
+		return jwk, nil
+	case ed25519.PrivateKey:
+		jwk, err := NewJWK(key.Public())
+		if err != nil {
+			return nil, err
+		}
+		jwk.D = enc(key.Seed())
+		return jwk, nil
+	case []byte:
+		return &JWK{KeyType: "oct", K: enc(key)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// Thumbprint returns the JWK thumbprint of the key, as described in RFC 7638:
+// the base64url-encoded SHA-256 hash of the key's required members. It is
+// commonly used as the key ID.
+func (k *JWK) Thumbprint() (string, error) {
+	// The members are in lexicographic order, as RFC 7638 requires, and
+	// json.Marshal of a map sorts its keys.
+	var members map[string]string
+	switch k.KeyType {
+	case "RSA":
+		members = map[string]string{"e": k.E, "kty": k.KeyType, "n": k.N}
+	case "EC":
+		members = map[string]string{"crv": k.Curve, "kty": k.KeyType, "x": k.X, "y": k.Y}
+	case "OKP":
+		members = map[string]string{"crv": k.Curve, "kty": k.KeyType, "x": k.X}
+	case "oct":
+		members = map[string]string{"k": k.K, "kty": k.KeyType}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+	for name, value := range members {
+		if value == "" {
+			return "", fmt.Errorf("missing %q member", name)
+		}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// PublicKey returns the public key described by the JWK. The result is an
+// *rsa.PublicKey, an *ecdsa.PublicKey or an ed25519.PublicKey.
+func (k *JWK) PublicKey() (interface{}, error) {
+	switch k.KeyType {
+	case "RSA":
+		return k.rsaPublicKey()
+	case "EC":
+		return k.ecdsaPublicKey()
+	case "OKP":
+		return k.ed25519PublicKey()
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
+
+// PrivateKey returns the private key described by the JWK. The result is an
+// *rsa.PrivateKey, an *ecdsa.PrivateKey, an ed25519.PrivateKey, or a []byte
+// secret for a symmetric key.
+func (k *JWK) PrivateKey() (interface{}, error) {
+	if k.KeyType == "oct" {
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, errors.New("invalid symmetric key")
+		}
+		return secret, nil
+	}
+	if k.D == "" {
+		return nil, errors.New("not a private key")
+	}
+	switch k.KeyType {
+	case "RSA":
+		return k.rsaPrivateKey()
+	case "EC":
+		return k.ecdsaPrivateKey()
+	case "OKP":
+		return k.ed25519PrivateKey()
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
+
+// rsaPrivateKey returns the RSA private key described by the JWK.
+func (k *JWK) rsaPrivateKey() (*rsa.PrivateKey, error) {
+	pub, err := k.rsaPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	d, err := decodeBigInt(k.D)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RSA private exponent: %w", err)
+	}
+	key := &rsa.PrivateKey{PublicKey: *pub, D: d}
+	if k.P != "" || k.Q != "" {
+		p, err := decodeBigInt(k.P)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA prime: %w", err)
+		}
+		q, err := decodeBigInt(k.Q)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA prime: %w", err)
+		}
+		key.Primes = []*big.Int{p, q}
+	}
+	if err := key.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid RSA private key: %w", err)
+	}
+	key.Precompute()
+	return key, nil
+}
+
+// ecdsaPrivateKey returns the ECDSA private key described by the JWK.
+func (k *JWK) ecdsaPrivateKey() (*ecdsa.PrivateKey, error) {
+	pub, err := k.ecdsaPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	d, err := base64.RawURLEncoding.DecodeString(k.D)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC private key: %w", err)
+	}
+	if len(d) != (pub.Curve.Params().BitSize+7)/8 {
+		return nil, errors.New("invalid EC private key length")
+	}
+	key := &ecdsa.PrivateKey{PublicKey: *pub, D: new(big.Int).SetBytes(d)}
+	// Let crypto/ecdh check the private key, and that it matches the public
+	// key.
+	priv, err := key.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC private key: %w", err)
+	}
+	if ecdhPub, err := pub.ECDH(); err != nil || !priv.PublicKey().Equal(ecdhPub) {
+		return nil, errors.New("EC private key does not match public key")
+	}
+	return key, nil
+}
+
+// ed25519PrivateKey returns the Ed25519 private key described by the JWK.
+func (k *JWK) ed25519PrivateKey() (ed25519.PrivateKey, error) {
+	pub, err := k.ed25519PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	d, err := base64.RawURLEncoding.DecodeString(k.D)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ed25519 private key: %w", err)
+	}
+	if len(d) != ed25519.SeedSize {
+		return nil, errors.New("invalid Ed25519 private key length")
+	}
+	key := ed25519.NewKeyFromSeed(d)
+	if !pub.Equal(key.Public()) {
+		return nil, errors.New("Ed25519 private key does not match public key")
+	}
+	return key, nil
+}
+
+// rsaPublicKey returns the RSA public key described by the JWK.
+func (k *JWK) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 || e.Int64() < 2 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+// ecdsaPublicKey returns the ECDSA public key described by the JWK.
+func (k *JWK) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var ecdhCurve ecdh.Curve
+	switch k.Curve {
+	case "P-256":
+		curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x) != size || len(y) != size {
+		return nil, errors.New("invalid EC coordinate length")
+	}
+	// Let crypto/ecdh check that the point is on the curve.
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("invalid EC point: %w", err)
+	}
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+// ed25519PublicKey returns the Ed25519 public key described by the JWK.
+func (k *JWK) ed25519PublicKey() (ed25519.PublicKey, error) {
+	if k.Curve != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ed25519 public key: %w", err)
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 public key length")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian unsigned integer.
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,115 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_JWK_PrivateKey_Returns_The_Private_Key(t *testing.T) {
+	enc := base64.RawURLEncoding.EncodeToString
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	rsaJWK := testJWK(t, "rsa", &rsaKey.PublicKey)
+	rsaJWK.D = enc(rsaKey.D.Bytes())
+	rsaJWK.P = enc(rsaKey.Primes[0].Bytes())
+	rsaJWK.Q = enc(rsaKey.Primes[1].Bytes())
+	ecJWK := testJWK(t, "ec", &ecKey.PublicKey)
+	ecJWK.D = enc(ecKey.D.FillBytes(make([]byte, 48)))
+	edJWK := testJWK(t, "ed", edKey.Public())
+	edJWK.D = enc(edKey.Seed())
+
+	key, err := rsaJWK.PrivateKey()
+	assert.NoError(t, err)
+	assert.True(t, rsaKey.Equal(key))
+	key, err = ecJWK.PrivateKey()
+	assert.NoError(t, err)
+	assert.True(t, ecKey.Equal(key))
+	key, err = edJWK.PrivateKey()
+	assert.NoError(t, err)
+	assert.True(t, edKey.Equal(key))
+	key, err = (&JWK{KeyType: "oct", K: enc([]byte("secret"))}).PrivateKey()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), key)
+}
+
+func Test_That_JWK_PrivateKey_Rejects_Invalid_Keys(t *testing.T) {
+	enc := base64.RawURLEncoding.EncodeToString
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	public := testJWK(t, "ec", &ecKey.PublicKey)
+	_, err = public.PrivateKey()
+	assert.Error(t, err)
+
+	mismatched := testJWK(t, "ec", &ecKey.PublicKey)
+	mismatched.D = enc(otherKey.D.FillBytes(make([]byte, 32)))
+	_, err = mismatched.PrivateKey()
+	assert.Error(t, err)
+
+	_, err = (&JWK{KeyType: "oct"}).PrivateKey()
+	assert.Error(t, err)
+}
+
+func Test_That_NewPrivateJWK_Round_Trips_Through_PrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	for _, key := range []interface{}{rsaKey, ecKey, edKey, []byte("secret")} {
+		jwk, err := NewPrivateJWK(key)
+		assert.NoError(t, err)
+		got, err := jwk.PrivateKey()
+		assert.NoError(t, err)
+		assert.Equal(t, key, got)
+	}
+
+	_, err = NewPrivateJWK(&rsaKey.PublicKey)
+	assert.Error(t, err)
+}
+
+func Test_That_NewPrivateJWK_Does_Not_Modify_An_RSA_Key(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	precomputed, err := NewPrivateJWK(rsaKey)
+	assert.NoError(t, err)
+
+	bare := &rsa.PrivateKey{PublicKey: rsaKey.PublicKey, D: rsaKey.D, Primes: rsaKey.Primes}
+	jwk, err := NewPrivateJWK(bare)
+	assert.NoError(t, err)
+	assert.Nil(t, bare.Precomputed.Dp)
+	assert.Equal(t, precomputed, jwk)
+}
+
+// Test vector from RFC 7638, section 3.1.
+func Test_That_JWK_Thumbprint_Computes_The_RFC_7638_Thumbprint(t *testing.T) {
+	jwk := &JWK{
+		KeyType:   "RSA",
+		KeyID:     "2011-04-29",
+		Algorithm: "RS256",
+		N:         "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:         "AQAB",
+	}
+	thumbprint, err := jwk.Thumbprint()
+	assert.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+
+	_, err = (&JWK{KeyType: "EC", Curve: "P-256"}).Thumbprint()
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// defaultJWKSMaxAge is the Cache-Control max-age of a JWKS document served for
+// a KeyRing that does not rotate on a schedule.
+const defaultJWKSMaxAge = 5 * time.Minute
+
+// NewJWKSHandler returns an http.Handler that serves the verification keys of
+// the given KeyRing as a JSON Web Key Set. Symmetric keys are never published.
+//
+// The Cache-Control max-age of the response is the time until the key ring's
+// next rotation, or five minutes if keys are not rotated on a schedule. The
+// response has an ETag, and conditional requests with If-None-Match are
+// answered with 304 Not Modified.
+func NewJWKSHandler(keys *KeyRing) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		// Active rotates the key if it is due, so that the published keys and
+		// the max-age are current.
+		_, _ = keys.Active()
+		body, err := json.Marshal(PublicJWKS(keys))
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		sum := sha256.Sum256(body)
+		etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+		maxAge := defaultJWKSMaxAge
+		if next := keys.NextRotation(); !next.IsZero() {
+			maxAge = max(next.Sub(keys.now()), 0)
+		}
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	})
+}
+
+// PublicJWKS returns the verification keys of the given KeyRing as a JSON Web
+// Key Set. Symmetric keys are omitted.
+func PublicJWKS(keys *KeyRing) *JWKS {
+	jwks := &JWKS{Keys: []JWK{}}
+	for _, key := range keys.VerificationKeys() {
+		jwk, err := NewJWK(key.VerificationKey())
+		if err != nil {
+			continue
+		}
+		jwk.KeyID = key.ID
+		jwk.Use = "sig"
+		jwk.Algorithm = key.Method.Alg()
+		jwks.Keys = append(jwks.Keys, *jwk)
+	}
+	return jwks
+}
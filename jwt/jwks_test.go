@@ -0,0 +1,91 @@
+package jwt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_NewJWK_Round_Trips_Public_Keys(t *testing.T) {
+	keys := newTestKeys(t)
+	for _, key := range []interface{}{&keys.rsa.PublicKey, &keys.ecdsa.PublicKey, keys.ed25519.Public()} {
+		jwk, err := NewJWK(key)
+		assert.NoError(t, err)
+		got, err := jwk.PublicKey()
+		assert.NoError(t, err)
+		assert.Equal(t, key, got)
+	}
+	_, err := NewJWK([]byte("secret"))
+	assert.Error(t, err)
+}
+
+func Test_That_NewJWKSHandler_Serves_The_Verification_Keys(t *testing.T) {
+	now := time.Now()
+	kr := NewKeyRing(WithKeyRingAlgorithm("ES256"), WithKeyRingRotation(time.Hour))
+	kr.now = func() time.Time { return now }
+	hmac, err := GenerateSigningKey("HS256")
+	assert.NoError(t, err)
+	kr.Add(hmac)
+	_, err = kr.Rotate()
+	assert.NoError(t, err)
+	now = now.Add(20 * time.Minute)
+
+	rec := httptest.NewRecorder()
+	NewJWKSHandler(kr).ServeHTTP(rec, httptest.NewRequest("GET", "/jwks", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/jwk-set+json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=2400", rec.Header().Get("Cache-Control"))
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+	var jwks JWKS
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jwks))
+	assert.Len(t, jwks.Keys, 1, "symmetric keys must not be published")
+	active, err := kr.Active()
+	assert.NoError(t, err)
+	assert.Equal(t, active.ID, jwks.Keys[0].KeyID)
+	assert.Equal(t, "ES256", jwks.Keys[0].Algorithm)
+	assert.Equal(t, "sig", jwks.Keys[0].Use)
+}
+
+func Test_That_NewJWKSHandler_Answers_Conditional_Requests(t *testing.T) {
+	kr := NewKeyRing(WithKeyRingAlgorithm("RS256"))
+	handler := NewJWKSHandler(kr)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/jwks", nil))
+	etag := rec.Header().Get("ETag")
+	assert.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+
+	req := httptest.NewRequest("GET", "/jwks", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	_, err := kr.Rotate()
+	assert.NoError(t, err)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+}
+
+func Test_That_A_KeySet_Can_Verify_Tokens_Using_A_JWKS_Handler(t *testing.T) {
+	kr := NewKeyRing(WithKeyRingAlgorithm("EdDSA"))
+	server := httptest.NewServer(NewJWKSHandler(kr))
+	defer server.Close()
+
+	token, err := NewSigner(kr).Sign(&gojwt.RegisteredClaims{Subject: "test"})
+	assert.NoError(t, err)
+
+	middleware := NewMiddleware(WithKey(NewKeySetFromURL(server.URL)))
+	claims, err := middleware.parseClaims(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", claims.(*gojwt.RegisteredClaims).Subject)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setValue parses an assignment and applies it to m. The assignment has one of
+// the forms:
+//
+//	key=value       value is a string
+//	key:=json       value is any JSON value
+//	key:type=value  value is a string, int, float, bool or json
+//
+// The key is used as is, so names such as "https://example.com/roles" are kept
+// whole. If nested is true, the key is instead a dot-separated path of object
+// keys, such as "tenant.id", and objects along the path are created as needed.
+// A literal dot in a path is written as "\.".
+func setValue(m map[string]interface{}, assignment string, nested bool) error {
+	spec, raw, ok := strings.Cut(assignment, "=")
+	if !ok {
+		return fmt.Errorf("%q is not of the form key=value", assignment)
+	}
+	path, typ := spec, "string"
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		switch t := spec[i+1:]; t {
+		case "":
+			path, typ = spec[:i], "json"
+		case "string", "int", "float", "bool", "json":
+			path, typ = spec[:i], t
+		}
+	}
+	value, err := parseValue(raw, typ)
+	if err != nil {
+		return fmt.Errorf("%s: %w", assignment, err)
+	}
+	keys := []string{path}
+	if nested {
+		keys = splitPath(path)
+	}
+	if keys[0] == "" {
+		return fmt.Errorf("%q has no key", assignment)
+	}
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[key] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+	return nil
+}
+
+// parseValue parses a value of the given type.
+func parseValue(raw, typ string) (interface{}, error) {
+	switch typ {
+	case "int":
+		return strconv.ParseInt(raw, 10, 64)
+	case "float":
+		return strconv.ParseFloat(raw, 64)
+	case "bool":
+		return strconv.ParseBool(raw)
+	case "json":
+		var v interface{}
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	return raw, nil
+}
+
+// splitPath splits a dotted path into keys. A backslash escapes a dot.
+func splitPath(path string) []string {
+	var keys []string
+	var key strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			key.WriteByte('.')
+			i++
+		case path[i] == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(path[i])
+		}
+	}
+	return append(keys, key.String())
+}
+
+// readClaimsFile reads a JSON or YAML object from a file. Values are returned
+// as they would be decoded from JSON.
+func readClaimsFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// YAML is a superset of JSON, so this reads either.
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if v == nil {
+		return map[string]interface{}{}, nil
+	}
+	// Round-trip through JSON so that values have the types they would have in
+	// a token, such as RFC 3339 strings for YAML timestamps.
+	data, err = json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: must hold an object", path)
+	}
+	return m, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_splitPath_Splits_On_Unescaped_Dots(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		keys []string
+	}{
+		{"sub", []string{"sub"}},
+		{"tenant.id", []string{"tenant", "id"}},
+		{"a.b.c", []string{"a", "b", "c"}},
+		{`example\.com.roles`, []string{"example.com", "roles"}},
+		{`https://example\.com/roles`, []string{"https://example.com/roles"}},
+		{`a\b`, []string{`a\b`}},
+		{"a.", []string{"a", ""}},
+		{"", []string{""}},
+	} {
+		assert.Equal(t, tc.keys, splitPath(tc.path), tc.path)
+	}
+}
+
+func Test_That_setValue_Applies_Assignments(t *testing.T) {
+	for _, tc := range []struct {
+		assignment string
+		nested     bool
+		want       map[string]interface{}
+	}{
+		{"sub=jdoe", false, map[string]interface{}{"sub": "jdoe"}},
+		{"https://example.com/roles=admin", false, map[string]interface{}{"https://example.com/roles": "admin"}},
+		{"https://example.com/roles:=[\"admin\"]", false, map[string]interface{}{"https://example.com/roles": []interface{}{"admin"}}},
+		{"tenant.id=acme", false, map[string]interface{}{"tenant.id": "acme"}},
+		{"tenant.id=acme", true, map[string]interface{}{"tenant": map[string]interface{}{"id": "acme"}}},
+		{`https://example\.com/roles=admin`, true, map[string]interface{}{"https://example.com/roles": "admin"}},
+		{"n:int=5", false, map[string]interface{}{"n": int64(5)}},
+		{"f:float=1.5", false, map[string]interface{}{"f": 1.5}},
+		{"b:bool=true", false, map[string]interface{}{"b": true}},
+		{"s:string=5", false, map[string]interface{}{"s": "5"}},
+		{"url=https://example.com/a=b", false, map[string]interface{}{"url": "https://example.com/a=b"}},
+		{"a:b=c", false, map[string]interface{}{"a:b": "c"}},
+	} {
+		m := map[string]interface{}{}
+		assert.NoError(t, setValue(m, tc.assignment, tc.nested), tc.assignment)
+		assert.Equal(t, tc.want, m, tc.assignment)
+	}
+}
+
+func Test_That_setValue_Merges_Nested_Paths(t *testing.T) {
+	m := map[string]interface{}{"tenant": map[string]interface{}{"id": "acme"}}
+	assert.NoError(t, setValue(m, "tenant.name=Acme", true))
+	assert.Equal(t, map[string]interface{}{"tenant": map[string]interface{}{"id": "acme", "name": "Acme"}}, m)
+}
+
+func Test_That_setValue_Rejects_Invalid_Assignments(t *testing.T) {
+	for _, assignment := range []string{"sub", "=jdoe", ":=1", "n:int=x", "b:bool=maybe", "j:=nope"} {
+		assert.Error(t, setValue(map[string]interface{}{}, assignment, false), assignment)
+	}
+}
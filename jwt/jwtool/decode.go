@@ -5,17 +5,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"strings"
 
 	gojwt "github.com/golang-jwt/jwt/v5"
 	"github.com/spf13/cobra"
+
+	"github.com/smxlong/kit/jwt"
 )
 
 // decode is the decode command.
 type decode struct {
 	key        string // key to sign with
+	keyFile    string // file or JWKS URL with the key to verify with
 	token      string // token to decode
 	noValidate bool   // don't validate the token
+	decryptKey string // file with the private key to decrypt a JWE with
 }
 
 // Command returns the decode command.
@@ -28,35 +32,45 @@ func (d *decode) Command() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVarP(&d.key, "key", "k", "", "The key to sign with.")
+	cmd.Flags().StringVarP(&d.keyFile, "key-file", "K", "", "A file with the key to verify with: a PEM key or certificate, a JWK, a JWKS, or a shared secret. May also be the URL of a JWKS.")
 	cmd.Flags().StringVarP(&d.token, "token", "t", "", "The token to decode. If not given, token will be read from stdin.")
 	cmd.Flags().BoolVarP(&d.noValidate, "no-validate", "N", false, "Don't validate the token.")
+	cmd.Flags().StringVar(&d.decryptKey, "decrypt-with", "", "A file with the private key (PEM) or shared secret to decrypt a JWE with.")
 	return cmd
 }
 
 func (d *decode) do(cmd *cobra.Command, args []string) error {
 	if d.token == "" {
-		scanner := bufio.NewScanner(os.Stdin)
+		scanner := bufio.NewScanner(cmd.InOrStdin())
 		if !scanner.Scan() {
 			return errors.New("no token given")
 		}
 		d.token = scanner.Text()
 	}
+	if d.decryptKey != "" && strings.Count(d.token, ".") == 4 {
+		key, err := readKey(d.decryptKey)
+		if err != nil {
+			return err
+		}
+		if d.token, err = jwt.DecryptToken(d.token, key); err != nil {
+			return err
+		}
+	}
 
+	keyfunc, err := verificationKeyfunc(d.keyFile, d.key)
+	if err != nil {
+		return err
+	}
 	claims := gojwt.MapClaims{}
-	tok, err := gojwt.ParseWithClaims(d.token, &claims, func(token *gojwt.Token) (interface{}, error) {
-		if d.key == "" {
-			return nil, errors.New("no key specified")
-		}
-		return []byte(d.key), nil
-	})
+	tok, err := gojwt.ParseWithClaims(d.token, &claims, keyfunc)
 	if err != nil {
 		if tok != nil && d.noValidate {
-			fmt.Printf("WARNING: %v\n", err)
+			fmt.Fprintf(cmd.OutOrStdout(), "WARNING: %v\n", err)
 		} else {
 			return err
 		}
 	}
-	enc := json.NewEncoder(os.Stdout)
+	enc := json.NewEncoder(cmd.OutOrStdout())
 	enc.SetIndent("", "  ")
 	if err := enc.Encode(tok); err != nil {
 		return err
@@ -1,24 +1,35 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	gojwt "github.com/golang-jwt/jwt/v5"
 	"github.com/spf13/cobra"
+
+	"github.com/smxlong/kit/jwt"
 )
 
 // encode is the encode command.
 type encode struct {
-	key       string   // key to sign with
-	claims    []string // key=value
-	expiresIn time.Duration
-	expiresAt time.Time
-	notBefore time.Time
-	subject   string
-	audience  []string
-	issuer    string
+	key        string   // key to sign with
+	keyFile    string   // file with the key to sign with
+	kid        string   // key ID
+	alg        string   // signing algorithm
+	claims     []string // claim assignments, such as key=value
+	claimPaths []string // claim assignments with dotted paths, such as a.b=value
+	claimsFile string   // JSON or YAML file with claims
+	headers    []string // header assignments, such as key=value
+	expiresIn  time.Duration
+	expiresAt  time.Time
+	notBefore  time.Time
+	subject    string
+	audience   []string
+	issuer     string
+	encryptTo  string // file with the key of the recipient to encrypt to
+	encAlg     string // JWE key management algorithm
+	enc        string // JWE content encryption algorithm
 }
 
 // Command returns the encode command.
@@ -45,47 +56,108 @@ func (e *encode) Command() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVarP(&e.key, "key", "k", "", "The key to sign with.")
-	cmd.Flags().StringArrayVarP(&e.claims, "claim", "c", nil, "A \"key=value\" claim to add to the JWT. Can be specified multiple times.")
+	cmd.Flags().StringVarP(&e.keyFile, "key-file", "K", "", "A file with the key to sign with: a PEM private key, a JWK, a JWKS, or a shared secret.")
+	cmd.Flags().StringVar(&e.kid, "kid", "", "The key ID to put in the kid header. Selects the key from a JWKS. Defaults to the ID in a JWK.")
+	cmd.Flags().StringVar(&e.alg, "alg", "", "The signing algorithm, such as HS256, RS256, PS256, ES256 or EdDSA. Defaults to one suitable for the key.")
+	cmd.Flags().StringArrayVarP(&e.claims, "claim", "c", nil, "A claim to add to the JWT, as \"key=string\", \"key:=json\" or \"key:type=value\", where type is string, int, float, bool or json. The key is used as is, even if it contains dots. Can be specified multiple times.")
+	cmd.Flags().StringArrayVar(&e.claimPaths, "claim-path", nil, "A claim to add to the JWT, in the same forms as --claim, but the key is a dotted path such as \"tenant.id\" that builds nested objects. A literal dot is written as \"\\.\". Applied after --claim. Can be specified multiple times.")
+	cmd.Flags().StringVarP(&e.claimsFile, "claims-file", "C", "", "A JSON or YAML file with claims to start from. Other flags override its claims.")
+	cmd.Flags().StringArrayVarP(&e.headers, "header", "H", nil, "A JOSE header field to set, in the same forms as --claim. Can be specified multiple times.")
 	cmd.Flags().DurationVarP(&e.expiresIn, "expires-in", "e", 24*time.Hour, "The duration until the JWT expires.")
 	cmd.Flags().StringVarP(&expiresAt, "expires-at", "E", "", "The time at which the JWT expires.")
 	cmd.Flags().StringVarP(&notBefore, "not-before", "n", "", "The time before which the JWT is not valid.")
 	cmd.Flags().StringVarP(&e.subject, "subject", "s", "jdoe@example.com", "The subject of the JWT.")
 	cmd.Flags().StringArrayVarP(&e.audience, "audience", "a", []string{"https://example.com"}, "The audience of the JWT. Can be specified multiple times.")
 	cmd.Flags().StringVarP(&e.issuer, "issuer", "i", "https://example.com", "The issuer of the JWT.")
+	cmd.Flags().StringVar(&e.encryptTo, "encrypt-to", "", "A file with the recipient's public key (PEM) or shared secret. If given, the JWT is encrypted as a JWE.")
+	cmd.Flags().StringVar(&e.encAlg, "encrypt-alg", "", "The JWE key management algorithm: RSA-OAEP, RSA-OAEP-256, ECDH-ES or dir. Defaults to one suitable for the key.")
+	cmd.Flags().StringVar(&e.enc, "encrypt-enc", "A256GCM", "The JWE content encryption algorithm: A128GCM, A192GCM or A256GCM.")
 	return cmd
 }
 
 func (e *encode) do(cmd *cobra.Command, args []string) error {
 	claims := map[string]interface{}{}
-	for _, kv := range e.claims {
-		kvPair := strings.SplitN(kv, "=", 2)
-		if len(kvPair) != 2 {
-			return cmd.Help()
+	if e.claimsFile != "" {
+		var err error
+		if claims, err = readClaimsFile(e.claimsFile); err != nil {
+			return err
 		}
-		claims[kvPair[0]] = kvPair[1]
 	}
-	if e.expiresIn != 0 {
-		claims["exp"] = time.Now().Add(e.expiresIn).Unix()
+	// The registered claims flags override the claims file only if they were
+	// given, or the file does not set the claim.
+	flags := cmd.Flags()
+	setDefault := func(name, flag string, value interface{}) {
+		if _, ok := claims[name]; !ok || flags.Changed(flag) {
+			claims[name] = value
+		}
 	}
 	if !e.expiresAt.IsZero() {
-		claims["exp"] = e.expiresAt.Unix()
+		setDefault("exp", "expires-at", e.expiresAt.Unix())
+	} else if e.expiresIn != 0 {
+		setDefault("exp", "expires-in", time.Now().Add(e.expiresIn).Unix())
 	}
 	if !e.notBefore.IsZero() {
-		claims["nbf"] = e.notBefore.Unix()
+		setDefault("nbf", "not-before", e.notBefore.Unix())
 	}
 	if e.subject != "" {
-		claims["sub"] = e.subject
+		setDefault("sub", "subject", e.subject)
 	}
 	if len(e.audience) > 0 {
-		claims["aud"] = e.audience
+		setDefault("aud", "audience", e.audience)
 	}
 	if e.issuer != "" {
-		claims["iss"] = e.issuer
+		setDefault("iss", "issuer", e.issuer)
+	}
+	for _, assignment := range e.claims {
+		if err := setValue(claims, assignment, false); err != nil {
+			return err
+		}
+	}
+	for _, assignment := range e.claimPaths {
+		if err := setValue(claims, assignment, true); err != nil {
+			return err
+		}
 	}
-	tok, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims(claims)).SignedString([]byte(e.key))
+	key, kid, method, err := signingKey(e.keyFile, e.key, e.kid, e.alg)
 	if err != nil {
 		return err
 	}
-	fmt.Println(tok)
+	t := gojwt.NewWithClaims(method, gojwt.MapClaims(claims))
+	if kid != "" {
+		t.Header["kid"] = kid
+	}
+	for _, assignment := range e.headers {
+		if err := setValue(t.Header, assignment, false); err != nil {
+			return err
+		}
+	}
+	if t.Header["alg"] != method.Alg() {
+		return errors.New("the alg header cannot be set with --header; use --alg")
+	}
+	tok, err := t.SignedString(key)
+	if err != nil {
+		return err
+	}
+	if e.encryptTo != "" {
+		if tok, err = e.encrypt(tok); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), tok)
 	return nil
 }
+
+// encrypt encrypts the token to the recipient's key.
+func (e *encode) encrypt(tok string) (string, error) {
+	key, err := readKey(e.encryptTo)
+	if err != nil {
+		return "", err
+	}
+	alg := e.encAlg
+	if alg == "" {
+		if alg, err = encryptionAlgorithm(key); err != nil {
+			return "", err
+		}
+	}
+	return jwt.EncryptToken(tok, key, alg, e.enc)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// runEncode runs the encode command with the given arguments and returns the
+// token it prints.
+func runEncode(args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := (&encode{}).Command()
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return strings.TrimSpace(out.String()), err
+}
+
+// runDecode runs the decode command with the given arguments and returns the
+// decoded token.
+func runDecode(args ...string) (map[string]interface{}, error) {
+	var out bytes.Buffer
+	cmd := (&decode{}).Command()
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		return nil, err
+	}
+	tok := map[string]interface{}{}
+	err := json.Unmarshal(out.Bytes(), &tok)
+	return tok, err
+}
+
+func Test_That_encode_And_decode_Round_Trip_With_PEM_Key_Files(t *testing.T) {
+	for _, tc := range []struct {
+		keyType string
+		alg     string
+	}{
+		{"rsa", "RS256"},
+		{"ec", "ES256"},
+		{"ed25519", "EdDSA"},
+	} {
+		t.Run(tc.alg, func(t *testing.T) {
+			dir := t.TempDir()
+			private, public := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub.pem")
+			_, err := runKeygen("--type", tc.keyType, "--out", private, "--public-out", public)
+			assert.NoError(t, err)
+
+			token, err := runEncode("--key-file", private, "--kid", "k1", "--subject", "alice")
+			assert.NoError(t, err)
+			tok, err := runDecode("--key-file", public, "--token", token)
+			assert.NoError(t, err)
+			assert.Equal(t, true, tok["Valid"])
+			header := tok["Header"].(map[string]interface{})
+			assert.Equal(t, tc.alg, header["alg"])
+			assert.Equal(t, "k1", header["kid"])
+			assert.Equal(t, "alice", tok["Claims"].(map[string]interface{})["sub"])
+
+			// The private key also verifies, reduced to its public key.
+			_, err = runDecode("--key-file", private, "--token", token)
+			assert.NoError(t, err)
+
+			other := filepath.Join(dir, "other.pem")
+			_, err = runKeygen("--type", tc.keyType, "--out", other)
+			assert.NoError(t, err)
+			_, err = runDecode("--key-file", other, "--token", token)
+			assert.ErrorIs(t, err, gojwt.ErrTokenSignatureInvalid)
+		})
+	}
+}
+
+func Test_That_encode_And_decode_Select_Keys_From_A_JWKS_By_Kid(t *testing.T) {
+	for _, tc := range []struct {
+		keyType string
+		alg     string
+	}{
+		{"rsa", "RS256"},
+		{"ec", "ES256"},
+		{"ed25519", "EdDSA"},
+	} {
+		t.Run(tc.alg, func(t *testing.T) {
+			dir := t.TempDir()
+			jwks := filepath.Join(dir, "jwks.json")
+			for _, kid := range []string{"k1", "k2"} {
+				_, err := runKeygen("--type", tc.keyType, "--format", "jwk", "--kid", kid, "--alg", tc.alg,
+					"--out", filepath.Join(dir, kid+".json"), "--jwks", jwks)
+				assert.NoError(t, err)
+			}
+
+			token, err := runEncode("--key-file", filepath.Join(dir, "k2.json"))
+			assert.NoError(t, err)
+			tok, err := runDecode("--key-file", jwks, "--token", token)
+			assert.NoError(t, err)
+			assert.Equal(t, true, tok["Valid"])
+			header := tok["Header"].(map[string]interface{})
+			assert.Equal(t, tc.alg, header["alg"])
+			assert.Equal(t, "k2", header["kid"])
+
+			// A key file has no key for another ID.
+			_, err = runEncode("--key-file", filepath.Join(dir, "k2.json"), "--kid", "k1")
+			assert.Error(t, err)
+
+			// A token signed by a different key claiming a known ID does not
+			// verify.
+			impostor := filepath.Join(t.TempDir(), "k1.json")
+			_, err = runKeygen("--type", tc.keyType, "--format", "jwk", "--kid", "k1", "--alg", tc.alg, "--out", impostor)
+			assert.NoError(t, err)
+			forged, err := runEncode("--key-file", impostor)
+			assert.NoError(t, err)
+			_, err = runDecode("--key-file", jwks, "--token", forged)
+			assert.ErrorIs(t, err, gojwt.ErrTokenSignatureInvalid)
+		})
+	}
+}
+
+func Test_That_encode_And_decode_Round_Trip_With_A_Secret_File(t *testing.T) {
+	path := writeFile(t, "secret", []byte("s3cret\n"))
+	token, err := runEncode("--key-file", path)
+	assert.NoError(t, err)
+	tok, err := runDecode("--key", "s3cret", "--token", token)
+	assert.NoError(t, err)
+	assert.Equal(t, true, tok["Valid"])
+	assert.Equal(t, "HS256", tok["Header"].(map[string]interface{})["alg"])
+}
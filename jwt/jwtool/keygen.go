@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/smxlong/kit/jwt"
+)
+
+// keygen is the keygen command.
+type keygen struct {
+	keyType   string // rsa, ec, ed25519 or oct
+	bits      int    // RSA or oct key size
+	curve     string // EC curve
+	from      string // file with a key to convert instead of generating one
+	format    string // pem or jwk
+	out       string // file for the private key
+	publicOut string // file for the public key
+	jwks      string // JWKS file to append the public key to
+	kid       string // key ID
+	alg       string // algorithm to record in the JWK
+	use       string // use to record in the JWK
+}
+
+// Command returns the keygen command.
+func (k *keygen) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate or convert a key.",
+		Long: `Generate a key, or convert a key between PEM and JWK formats.
+
+The private key is written to stdout, or to the --out file, and the public key
+to the --public-out file. The key ID is the key's RFC 7638 thumbprint, unless
+--kid is given. With --jwks, the public key is also appended to a JWKS file,
+which is created if it does not exist.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return k.do(cmd, args)
+		},
+	}
+	cmd.Flags().StringVarP(&k.keyType, "type", "t", "ec", "The key type: rsa, ec, ed25519 or oct.")
+	cmd.Flags().IntVarP(&k.bits, "bits", "b", 0, "The size of an rsa or oct key, in bits. Defaults to 2048 for rsa and 256 for oct.")
+	cmd.Flags().StringVarP(&k.curve, "curve", "c", "P-256", "The curve of an ec key: P-256, P-384 or P-521.")
+	cmd.Flags().StringVar(&k.from, "from", "", "A file with a PEM key or JWK to convert, instead of generating a key. With --type oct, a file with a raw secret.")
+	cmd.Flags().StringVarP(&k.format, "format", "f", "pem", "The output format: pem or jwk.")
+	cmd.Flags().StringVarP(&k.out, "out", "o", "", "The file to write the private key to. Defaults to stdout.")
+	cmd.Flags().StringVarP(&k.publicOut, "public-out", "p", "", "The file to write the public key to.")
+	cmd.Flags().StringVar(&k.jwks, "jwks", "", "A JWKS file to append the public key to.")
+	cmd.Flags().StringVar(&k.kid, "kid", "", "The key ID. Defaults to the key's thumbprint.")
+	cmd.Flags().StringVar(&k.alg, "alg", "", "The algorithm to record in the JWK, such as RS256 or ES256.")
+	cmd.Flags().StringVar(&k.use, "use", "sig", "The use to record in the JWK: sig or enc.")
+	return cmd
+}
+
+func (k *keygen) do(cmd *cobra.Command, args []string) error {
+	if k.format != "pem" && k.format != "jwk" {
+		return fmt.Errorf("unknown format %q", k.format)
+	}
+	var key interface{}
+	var err error
+	if k.from != "" {
+		key, err = k.read()
+	} else {
+		key, err = k.generate()
+	}
+	if err != nil {
+		return err
+	}
+
+	var public interface{}
+	if signer, ok := key.(crypto.Signer); ok {
+		public = signer.Public()
+	} else if _, ok := key.([]byte); !ok {
+		// Converting a public key.
+		public, key = key, nil
+	}
+	publicJWK, err := k.publicJWK(key, public)
+	if err != nil {
+		return err
+	}
+
+	if key != nil {
+		data, err := k.encode(key, publicJWK)
+		if err != nil {
+			return err
+		}
+		if err := writeOutput(cmd.OutOrStdout(), k.out, data, 0o600); err != nil {
+			return err
+		}
+	}
+	if public != nil && (k.publicOut != "" || key == nil) {
+		data, err := k.encode(public, publicJWK)
+		if err != nil {
+			return err
+		}
+		if err := writeOutput(cmd.OutOrStdout(), k.publicOut, data, 0o644); err != nil {
+			return err
+		}
+	}
+	if k.jwks != "" {
+		if public == nil {
+			return errors.New("symmetric keys cannot be added to a JWKS")
+		}
+		return appendJWKS(k.jwks, publicJWK)
+	}
+	return nil
+}
+
+// read reads the key to convert. A file that is neither a PEM key nor a JWK is
+// read as a raw secret only with --type oct, since it is more likely a mistake.
+func (k *keygen) read() (interface{}, error) {
+	keys, err := readKeys(k.from)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("%s: expected one key, found %d", k.from, len(keys))
+	}
+	if keys[0].raw && k.keyType != "oct" {
+		return nil, fmt.Errorf("%s: not a PEM key or JWK; use --type oct to import a raw secret", k.from)
+	}
+	return keys[0].key, nil
+}
+
+// generate generates a key.
+func (k *keygen) generate() (interface{}, error) {
+	switch k.keyType {
+	case "rsa":
+		bits := k.bits
+		if bits == 0 {
+			bits = 2048
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case "ec":
+		var curve elliptic.Curve
+		switch k.curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.curve)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	case "oct":
+		bits := k.bits
+		if bits == 0 {
+			bits = 256
+		}
+		if bits%8 != 0 || bits < 128 {
+			return nil, errors.New("oct keys must be a multiple of 8 bits, and at least 128 bits")
+		}
+		secret := make([]byte, bits/8)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return secret, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.keyType)
+}
+
+// publicJWK returns the JWK for the public key, or for the secret of a
+// symmetric key, with its key ID, algorithm and use set.
+func (k *keygen) publicJWK(key, public interface{}) (*jwt.JWK, error) {
+	var jwk *jwt.JWK
+	var err error
+	if public != nil {
+		jwk, err = jwt.NewJWK(public)
+	} else {
+		jwk, err = jwt.NewPrivateJWK(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	jwk.KeyID = k.kid
+	if jwk.KeyID == "" {
+		if jwk.KeyID, err = jwk.Thumbprint(); err != nil {
+			return nil, err
+		}
+	}
+	jwk.Algorithm = k.alg
+	jwk.Use = k.use
+	return jwk, nil
+}
+
+// encode encodes a private or public key in the output format. JWKs get the
+// key ID, algorithm and use of the given public JWK.
+func (k *keygen) encode(key interface{}, publicJWK *jwt.JWK) ([]byte, error) {
+	if k.format == "jwk" {
+		jwk, err := jwt.NewPrivateJWK(key)
+		if err != nil {
+			if jwk, err = jwt.NewJWK(key); err != nil {
+				return nil, err
+			}
+		}
+		jwk.KeyID, jwk.Algorithm, jwk.Use = publicJWK.KeyID, publicJWK.Algorithm, publicJWK.Use
+		data, err := json.MarshalIndent(jwk, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+	switch key := key.(type) {
+	case []byte:
+		return nil, errors.New("oct keys can only be written as JWK")
+	case crypto.Signer:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	default:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+	}
+}
+
+// writeOutput writes data to the file at path, or to w if path is empty.
+func writeOutput(w io.Writer, path string, data []byte, perm os.FileMode) error {
+	if path == "" {
+		_, err := w.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// appendJWKS appends a key to a JWKS file, creating it if it does not exist.
+// A key with the same ID as one already in the file is rejected.
+func appendJWKS(path string, jwk *jwt.JWK) error {
+	jwks := jwt.JWKS{Keys: []jwt.JWK{}}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &jwks); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, existing := range jwks.Keys {
+		if existing.KeyID == jwk.KeyID {
+			return fmt.Errorf("%s: already has a key with ID %q", path, jwk.KeyID)
+		}
+	}
+	jwks.Keys = append(jwks.Keys, *jwk)
+	if data, err = json.MarshalIndent(&jwks, "", "  "); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
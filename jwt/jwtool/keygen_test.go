@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smxlong/kit/jwt"
+)
+
+// runKeygen runs the keygen command with the given arguments and returns its
+// output.
+func runKeygen(args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := (&keygen{}).Command()
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func Test_That_keygen_From_Requires_Type_Oct_For_A_Raw_Secret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(path, []byte("not a key, but a secret"), 0o600))
+
+	_, err := runKeygen("--from", path)
+	assert.ErrorContains(t, err, "use --type oct")
+
+	out, err := runKeygen("--from", path, "--type", "oct", "--format", "jwk")
+	assert.NoError(t, err)
+	var jwk jwt.JWK
+	assert.NoError(t, json.Unmarshal([]byte(out), &jwk))
+	key, err := jwk.PrivateKey()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("not a key, but a secret"), key)
+}
+
+func Test_That_keygen_From_Converts_A_PEM_Key_To_A_JWK(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key.pem")
+	_, err := runKeygen("--type", "rsa", "--out", path)
+	assert.NoError(t, err)
+
+	out, err := runKeygen("--from", path, "--format", "jwk", "--kid", "k1")
+	assert.NoError(t, err)
+	var jwk jwt.JWK
+	assert.NoError(t, json.Unmarshal([]byte(out), &jwk))
+	assert.Equal(t, "RSA", jwk.KeyType)
+	assert.Equal(t, "k1", jwk.KeyID)
+	assert.NotEmpty(t, jwk.DP)
+}
+
+// parsePEMKey parses the PEM block in data with parse.
+func parsePEMKey[T any](t *testing.T, data []byte, parse func([]byte) (T, error)) T {
+	t.Helper()
+	block, _ := pem.Decode(data)
+	if !assert.NotNil(t, block) {
+		var zero T
+		return zero
+	}
+	key, err := parse(block.Bytes)
+	assert.NoError(t, err)
+	return key
+}
+
+func Test_That_keygen_Generates_EC_And_Ed25519_Keys(t *testing.T) {
+	for _, tc := range []struct {
+		args  []string
+		check func(t *testing.T, key interface{})
+	}{
+		{[]string{"--type", "ec"}, func(t *testing.T, key interface{}) {
+			assert.IsType(t, &ecdsa.PrivateKey{}, key)
+			assert.Equal(t, elliptic.P256(), key.(*ecdsa.PrivateKey).Curve)
+		}},
+		{[]string{"--type", "ec", "--curve", "P-384"}, func(t *testing.T, key interface{}) {
+			assert.IsType(t, &ecdsa.PrivateKey{}, key)
+			assert.Equal(t, elliptic.P384(), key.(*ecdsa.PrivateKey).Curve)
+		}},
+		{[]string{"--type", "ed25519"}, func(t *testing.T, key interface{}) {
+			assert.IsType(t, ed25519.PrivateKey{}, key)
+		}},
+	} {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			out, err := runKeygen(tc.args...)
+			assert.NoError(t, err)
+			tc.check(t, parsePEMKey(t, []byte(out), x509.ParsePKCS8PrivateKey))
+		})
+	}
+
+	_, err := runKeygen("--type", "ec", "--curve", "P-224")
+	assert.ErrorContains(t, err, "unsupported curve")
+}
+
+func Test_That_keygen_Writes_The_Public_Key_As_PEM(t *testing.T) {
+	dir := t.TempDir()
+	private, public := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub.pem")
+	out, err := runKeygen("--type", "ed25519", "--out", private, "--public-out", public)
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+
+	data, err := os.ReadFile(private)
+	assert.NoError(t, err)
+	key := parsePEMKey(t, data, x509.ParsePKCS8PrivateKey)
+	data, err = os.ReadFile(public)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "-----BEGIN PUBLIC KEY-----")
+	pub := parsePEMKey(t, data, x509.ParsePKIXPublicKey)
+	assert.Equal(t, key.(ed25519.PrivateKey).Public(), pub)
+
+	info, err := os.Stat(private)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func Test_That_keygen_Appends_Public_Keys_To_A_JWKS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	readJWKS := func() jwt.JWKS {
+		var jwks jwt.JWKS
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(data, &jwks))
+		return jwks
+	}
+
+	_, err := runKeygen("--type", "ec", "--kid", "k1", "--alg", "ES256", "--jwks", path)
+	assert.NoError(t, err)
+	jwks := readJWKS()
+	assert.Len(t, jwks.Keys, 1)
+
+	_, err = runKeygen("--type", "rsa", "--kid", "k2", "--alg", "RS256", "--jwks", path)
+	assert.NoError(t, err)
+	jwks = readJWKS()
+	if assert.Len(t, jwks.Keys, 2) {
+		assert.Equal(t, "k1", jwks.Keys[0].KeyID)
+		assert.Equal(t, "ES256", jwks.Keys[0].Algorithm)
+		assert.Equal(t, "k2", jwks.Keys[1].KeyID)
+		assert.Equal(t, "RS256", jwks.Keys[1].Algorithm)
+		for _, jwk := range jwks.Keys {
+			assert.Empty(t, jwk.D, "the JWKS should hold only public keys")
+			assert.Equal(t, "sig", jwk.Use)
+		}
+	}
+
+	_, err = runKeygen("--type", "ed25519", "--kid", "k1", "--jwks", path)
+	assert.ErrorContains(t, err, `already has a key with ID "k1"`)
+	assert.Len(t, readJWKS().Keys, 2)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/smxlong/kit/jwt"
+)
+
+// fileKey is a key read from a key file.
+type fileKey struct {
+	id  string      // key ID, if the file gives one
+	alg string      // algorithm, if the file gives one
+	key interface{} // the key
+	raw bool        // true if the file is read as a raw shared secret
+}
+
+// readKeys reads the keys in a key file. The file may hold a PEM public key,
+// certificate, or PKCS #1, PKCS #8 or SEC 1 private key; a JWK; or a JWKS. Any
+// other file is read as a raw shared secret, without a trailing newline, as
+// written by echo or an editor.
+func readKeys(path string) ([]fileKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := parsePEM(block)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return []fileKey{{key: key}}, nil
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		keys, err := parseJWKs(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return keys, nil
+	}
+	return []fileKey{{key: bytes.TrimRight(data, "\r\n"), raw: true}}, nil
+}
+
+// readKey reads a key file that holds a single key.
+func readKey(path string) (interface{}, error) {
+	keys, err := readKeys(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("%s: expected one key, found %d", path, len(keys))
+	}
+	return keys[0].key, nil
+}
+
+// parsePEM parses a PEM-encoded key or certificate.
+func parsePEM(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// parseJWKs parses a JWK or a JWKS. Private keys are returned as private keys.
+func parseJWKs(data []byte) ([]fileKey, error) {
+	var jwks jwt.JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+	if jwks.Keys == nil {
+		var jwk jwt.JWK
+		if err := json.Unmarshal(data, &jwk); err != nil {
+			return nil, err
+		}
+		jwks.Keys = []jwt.JWK{jwk}
+	}
+	keys := make([]fileKey, 0, len(jwks.Keys))
+	for i := range jwks.Keys {
+		jwk := &jwks.Keys[i]
+		var key interface{}
+		var err error
+		if jwk.D != "" || jwk.KeyType == "oct" {
+			key, err = jwk.PrivateKey()
+		} else {
+			key, err = jwk.PublicKey()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.KeyID, err)
+		}
+		keys = append(keys, fileKey{id: jwk.KeyID, alg: jwk.Algorithm, key: key})
+	}
+	return keys, nil
+}
+
+// selectKey returns the key with the given ID, or the only key if kid is
+// empty.
+func selectKey(keys []fileKey, kid string) (*fileKey, error) {
+	if kid == "" {
+		if len(keys) != 1 {
+			return nil, fmt.Errorf("found %d keys; select one with --kid", len(keys))
+		}
+		return &keys[0], nil
+	}
+	if len(keys) == 1 && keys[0].id == "" {
+		return &keys[0], nil
+	}
+	for i := range keys {
+		if keys[i].id == kid {
+			return &keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no key with ID %q", kid)
+}
+
+// signingKey returns the key, key ID and signing method to sign a token with.
+// The key is read from keyFile if it is given, and is otherwise the secret.
+// The algorithm defaults to the one given in a JWK, or one suitable for the
+// key.
+func signingKey(keyFile, secret, kid, alg string) (interface{}, string, gojwt.SigningMethod, error) {
+	keys := []fileKey{{key: []byte(secret)}}
+	if keyFile != "" {
+		var err error
+		if keys, err = readKeys(keyFile); err != nil {
+			return nil, "", nil, err
+		}
+	}
+	k, err := selectKey(keys, kid)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if kid == "" {
+		kid = k.id
+	}
+	if alg == "" {
+		alg = k.alg
+	}
+	if alg == "" {
+		if alg, err = signingAlgorithm(k.key); err != nil {
+			return nil, "", nil, err
+		}
+	}
+	method := gojwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, "", nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	return k.key, kid, method, nil
+}
+
+// verificationKeyfunc returns a Keyfunc for verifying tokens. The keys are
+// read from keyFile if it is given: a key file as for readKeys, or the URL of
+// a JWKS. Otherwise the key is the secret. Keys are selected by the token's
+// kid header, and private keys are reduced to their public keys.
+func verificationKeyfunc(keyFile, secret string) (gojwt.Keyfunc, error) {
+	if strings.HasPrefix(keyFile, "https://") || strings.HasPrefix(keyFile, "http://") {
+		return jwt.NewKeySetFromURL(keyFile).Keyfunc, nil
+	}
+	var keys []fileKey
+	switch {
+	case keyFile != "":
+		var err error
+		if keys, err = readKeys(keyFile); err != nil {
+			return nil, err
+		}
+	case secret != "":
+		keys = []fileKey{{key: []byte(secret)}}
+	default:
+		return func(*gojwt.Token) (interface{}, error) {
+			return nil, errors.New("no key specified")
+		}, nil
+	}
+	return func(token *gojwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, err := selectKey(keys, kid)
+		if err != nil {
+			return nil, err
+		}
+		if k.alg != "" && k.alg != token.Method.Alg() {
+			return nil, fmt.Errorf("key %q is not for use with %s", k.id, token.Method.Alg())
+		}
+		if signer, ok := k.key.(crypto.Signer); ok {
+			return signer.Public(), nil
+		}
+		return k.key, nil
+	}, nil
+}
+
+// signingAlgorithm returns the default signing algorithm for a key.
+func signingAlgorithm(key interface{}) (string, error) {
+	switch key := key.(type) {
+	case []byte:
+		return "HS256", nil
+	case *rsa.PrivateKey:
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return "ES256", nil
+		case 384:
+			return "ES384", nil
+		case 521:
+			return "ES512", nil
+		}
+	case ed25519.PrivateKey:
+		return "EdDSA", nil
+	}
+	return "", fmt.Errorf("cannot sign with a key of type %T", key)
+}
+
+// encryptionAlgorithm returns the default JWE key management algorithm for a
+// recipient's key.
+func encryptionAlgorithm(key interface{}) (string, error) {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return "RSA-OAEP-256", nil
+	case *ecdsa.PublicKey:
+		return "ECDH-ES", nil
+	case []byte:
+		return "dir", nil
+	}
+	return "", fmt.Errorf("cannot encrypt to a key of type %T", key)
+}
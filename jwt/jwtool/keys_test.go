@@ -0,0 +1,299 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smxlong/kit/jwt"
+)
+
+// writeFile writes data to a file in a temporary directory and returns its
+// path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// pemFile writes a PEM block to a file and returns its path.
+func pemFile(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	return writeFile(t, "key.pem", pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+}
+
+// jwksFile writes a JWKS of the keys to a file and returns its path.
+func jwksFile(t *testing.T, keys map[string]interface{}) string {
+	t.Helper()
+	jwks := jwt.JWKS{}
+	for kid, key := range keys {
+		jwk, err := jwt.NewPrivateJWK(key)
+		if err != nil {
+			jwk, err = jwt.NewJWK(key)
+		}
+		assert.NoError(t, err)
+		jwk.KeyID = kid
+		jwks.Keys = append(jwks.Keys, *jwk)
+	}
+	data, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+	return writeFile(t, "jwks.json", data)
+}
+
+// signedWith returns a token with the given kid signed with the key.
+func signedWith(t *testing.T, method gojwt.SigningMethod, kid string, key interface{}) *gojwt.Token {
+	t.Helper()
+	tok := gojwt.NewWithClaims(method, gojwt.MapClaims{"sub": "jdoe"})
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	signed, err := tok.SignedString(key)
+	assert.NoError(t, err)
+	parsed, _, err := gojwt.NewParser().ParseUnverified(signed, gojwt.MapClaims{})
+	assert.NoError(t, err)
+	return parsed
+}
+
+func Test_That_readKeys_Reads_PEM_Keys_And_Certificates(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	assert.NoError(t, err)
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	assert.NoError(t, err)
+	spki, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "jwtool"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &rsaKey.PublicKey, rsaKey)
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		blockType string
+		der       []byte
+		want      interface{}
+	}{
+		{"RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), rsaKey},
+		{"RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), &rsaKey.PublicKey},
+		{"EC PRIVATE KEY", sec1, ecKey},
+		{"PRIVATE KEY", pkcs8, edKey},
+		{"PUBLIC KEY", spki, &ecKey.PublicKey},
+		{"CERTIFICATE", cert, &rsaKey.PublicKey},
+	} {
+		keys, err := readKeys(pemFile(t, tc.blockType, tc.der))
+		assert.NoError(t, err, tc.blockType)
+		if assert.Len(t, keys, 1, tc.blockType) {
+			assert.IsType(t, tc.want, keys[0].key, tc.blockType)
+			assert.True(t, publicKey(tc.want).Equal(publicKey(keys[0].key)), tc.blockType)
+			assert.False(t, keys[0].raw, tc.blockType)
+		}
+	}
+
+	_, err = readKeys(pemFile(t, "DSA PRIVATE KEY", []byte("x")))
+	assert.ErrorContains(t, err, `unsupported PEM block "DSA PRIVATE KEY"`)
+	_, err = readKeys(pemFile(t, "PRIVATE KEY", []byte("not DER")))
+	assert.Error(t, err)
+}
+
+func Test_That_readKeys_Reads_JWKs_And_JWKSs(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	public, err := jwt.NewJWK(&ecKey.PublicKey)
+	assert.NoError(t, err)
+	public.KeyID, public.Algorithm = "pub", "ES256"
+	data, err := json.Marshal(public)
+	assert.NoError(t, err)
+
+	keys, err := readKeys(writeFile(t, "jwk.json", append([]byte("\n  "), data...)))
+	assert.NoError(t, err)
+	assert.Equal(t, []fileKey{{id: "pub", alg: "ES256", key: &ecKey.PublicKey}}, keys)
+
+	keys, err = readKeys(jwksFile(t, map[string]interface{}{"priv": ecKey}))
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, "priv", keys[0].id)
+		assert.True(t, ecKey.Equal(keys[0].key))
+	}
+
+	keys, err = readKeys(writeFile(t, "oct.json", []byte(`{"kty":"oct","kid":"s","k":"c2VjcmV0"}`)))
+	assert.NoError(t, err)
+	assert.Equal(t, []fileKey{{id: "s", key: []byte("secret")}}, keys)
+
+	_, err = readKeys(writeFile(t, "bad.json", []byte(`{"keys":[{"kty":"EC","kid":"bad","crv":"P-256","x":"AA","y":"AA"}]}`)))
+	assert.ErrorContains(t, err, `key "bad"`)
+	_, err = readKeys(writeFile(t, "bad.json", []byte(`{not json`)))
+	assert.Error(t, err)
+}
+
+func Test_That_readKeys_Reads_Raw_Secrets_Without_A_Trailing_Newline(t *testing.T) {
+	for _, data := range []string{"s3cret", "s3cret\n", "s3cret\r\n"} {
+		keys, err := readKeys(writeFile(t, "secret", []byte(data)))
+		assert.NoError(t, err)
+		assert.Equal(t, []fileKey{{key: []byte("s3cret"), raw: true}}, keys, "%q", data)
+	}
+	keys, err := readKeys(writeFile(t, "secret", []byte(" s3cret \n")))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(" s3cret "), keys[0].key)
+
+	_, err = readKeys(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_That_selectKey_Selects_By_Key_ID(t *testing.T) {
+	one := []fileKey{{key: []byte("a")}}
+	two := []fileKey{{id: "a", key: []byte("a")}, {id: "b", key: []byte("b")}}
+
+	k, err := selectKey(one, "")
+	assert.NoError(t, err)
+	assert.Equal(t, &one[0], k)
+	k, err = selectKey(one, "any")
+	assert.NoError(t, err)
+	assert.Equal(t, &one[0], k)
+
+	k, err = selectKey(two, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, &two[1], k)
+	_, err = selectKey(two, "")
+	assert.ErrorContains(t, err, "found 2 keys; select one with --kid")
+	_, err = selectKey(two, "c")
+	assert.ErrorContains(t, err, `no key with ID "c"`)
+	_, err = selectKey([]fileKey{{id: "a", key: []byte("a")}}, "c")
+	assert.ErrorContains(t, err, `no key with ID "c"`)
+}
+
+func Test_That_signingKey_Defaults_The_Algorithm_For_The_Key(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	path := jwksFile(t, map[string]interface{}{"rsa": rsaKey, "ec": p384, "ed": edKey})
+
+	for _, tc := range []struct {
+		kid, alg string
+		want     string
+	}{
+		{"rsa", "", "RS256"},
+		{"rsa", "PS384", "PS384"},
+		{"ec", "", "ES384"},
+		{"ed", "", "EdDSA"},
+	} {
+		key, kid, method, err := signingKey(path, "", tc.kid, tc.alg)
+		assert.NoError(t, err, tc.kid)
+		assert.Equal(t, tc.kid, kid)
+		assert.Equal(t, tc.want, method.Alg(), tc.kid)
+		assert.NotNil(t, key)
+	}
+
+	key, kid, method, err := signingKey("", "secret", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), key)
+	assert.Empty(t, kid)
+	assert.Equal(t, "HS256", method.Alg())
+
+	_, _, _, err = signingKey("", "secret", "", "XX256")
+	assert.ErrorContains(t, err, `unsupported algorithm "XX256"`)
+	_, _, _, err = signingKey(jwksFile(t, map[string]interface{}{"pub": &rsaKey.PublicKey}), "", "", "")
+	assert.ErrorContains(t, err, "cannot sign with a key of type *rsa.PublicKey")
+	_, _, _, err = signingKey(path, "", "", "")
+	assert.ErrorContains(t, err, "select one with --kid")
+}
+
+func Test_That_verificationKeyfunc_Returns_Public_Keys_By_Key_ID(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	keyfunc, err := verificationKeyfunc(jwksFile(t, map[string]interface{}{"rsa": rsaKey, "ec": ecKey}), "")
+	assert.NoError(t, err)
+
+	key, err := keyfunc(signedWith(t, gojwt.SigningMethodRS256, "rsa", rsaKey))
+	assert.NoError(t, err)
+	assert.Equal(t, &rsaKey.PublicKey, key)
+	key, err = keyfunc(signedWith(t, gojwt.SigningMethodES256, "ec", ecKey))
+	assert.NoError(t, err)
+	assert.Equal(t, &ecKey.PublicKey, key)
+	_, err = keyfunc(signedWith(t, gojwt.SigningMethodES256, "other", ecKey))
+	assert.ErrorContains(t, err, `no key with ID "other"`)
+
+	keyfunc, err = verificationKeyfunc("", "secret")
+	assert.NoError(t, err)
+	key, err = keyfunc(signedWith(t, gojwt.SigningMethodHS256, "", []byte("secret")))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), key)
+
+	keyfunc, err = verificationKeyfunc("", "")
+	assert.NoError(t, err)
+	_, err = keyfunc(signedWith(t, gojwt.SigningMethodHS256, "", []byte("secret")))
+	assert.ErrorContains(t, err, "no key specified")
+}
+
+func Test_That_verificationKeyfunc_Rejects_A_Key_For_Another_Algorithm(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	jwk, err := jwt.NewJWK(&ecKey.PublicKey)
+	assert.NoError(t, err)
+	jwk.KeyID, jwk.Algorithm = "ec", "ES384"
+	data, err := json.Marshal(jwk)
+	assert.NoError(t, err)
+	keyfunc, err := verificationKeyfunc(writeFile(t, "jwk.json", data), "")
+	assert.NoError(t, err)
+	_, err = keyfunc(signedWith(t, gojwt.SigningMethodES256, "ec", ecKey))
+	assert.ErrorContains(t, err, `key "ec" is not for use with ES256`)
+}
+
+func Test_That_verificationKeyfunc_Fetches_A_JWKS_URL(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	jwk, err := jwt.NewJWK(&ecKey.PublicKey)
+	assert.NoError(t, err)
+	jwk.KeyID = "ec"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwt.JWKS{Keys: []jwt.JWK{*jwk}})
+	}))
+	defer server.Close()
+
+	keyfunc, err := verificationKeyfunc(server.URL, "")
+	assert.NoError(t, err)
+	key, err := keyfunc(signedWith(t, gojwt.SigningMethodES256, "ec", ecKey))
+	assert.NoError(t, err)
+	assert.True(t, ecKey.PublicKey.Equal(key))
+	_, err = keyfunc(signedWith(t, gojwt.SigningMethodES256, "other", ecKey))
+	assert.ErrorIs(t, err, jwt.ErrUnknownKey)
+}
+
+// publicKey returns the public key of a private key, or a public key itself.
+func publicKey(key interface{}) interface{ Equal(crypto.PublicKey) bool } {
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+	return key.(interface{ Equal(crypto.PublicKey) bool })
+}
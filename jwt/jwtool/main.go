@@ -3,6 +3,7 @@ package main
 // jwtool is a tool for generating, validating, and manipulating JWTs.
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -14,19 +15,38 @@ import (
 // - encode
 // - decode
 // - validate
+// - keygen
+// - serve
 
 func main() {
 	j := &jwtool{}
 	if err := j.Command().Execute(); err != nil {
+		var exit *exitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
+// exitError is returned by a command to exit with a particular code. The
+// command has already reported the failure.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 // jwtool is the jwtool command.
 type jwtool struct {
-	encode encode
-	decode decode
+	encode   encode
+	decode   decode
+	validate validate
+	keygen   keygen
+	serve    serve
 }
 
 func (j *jwtool) Command() *cobra.Command {
@@ -40,6 +60,9 @@ func (j *jwtool) Command() *cobra.Command {
 	cmd.AddCommand(
 		j.encode.Command(),
 		j.decode.Command(),
+		j.validate.Command(),
+		j.keygen.Command(),
+		j.serve.Command(),
 	)
 	return cmd
 }
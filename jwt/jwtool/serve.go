@@ -0,0 +1,463 @@
+package main
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/smxlong/kit/jwt"
+	"github.com/smxlong/kit/signalcontext"
+	"github.com/smxlong/kit/webserver"
+)
+
+// serve is the serve command.
+type serve struct {
+	addr     string        // address to listen on
+	issuer   string        // issuer URL
+	config   string        // YAML file with clients and users
+	keyFile  string        // file with the signing key
+	kid      string        // key ID, or the key to select from a JWKS
+	alg      string        // signing algorithm
+	audience []string      // default audience of issued tokens
+	lifetime time.Duration // lifetime of issued tokens
+}
+
+// serveConfig is the clients and users file of the serve command.
+type serveConfig struct {
+	Clients []serveClient `yaml:"clients"`
+	Users   []serveUser   `yaml:"users"`
+}
+
+// serveClient is an OAuth 2.0 client. A client without a secret is a public
+// client, and authenticates with its ID alone.
+type serveClient struct {
+	ID       string                 `yaml:"id"`
+	Secret   string                 `yaml:"secret"`
+	Scopes   []string               `yaml:"scopes"`
+	Audience []string               `yaml:"audience"`
+	Claims   map[string]interface{} `yaml:"claims"`
+}
+
+// serveUser is a resource owner, for the password grant.
+type serveUser struct {
+	Username string                 `yaml:"username"`
+	Password string                 `yaml:"password"`
+	Subject  string                 `yaml:"subject"`
+	Scopes   []string               `yaml:"scopes"`
+	Claims   map[string]interface{} `yaml:"claims"`
+}
+
+// discoveryDocument is the provider metadata served by the serve command.
+type discoveryDocument struct {
+	jwt.ProviderMetadata
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// tokenError is an OAuth 2.0 error response, as described in RFC 6749,
+// section 5.2.
+type tokenError struct {
+	status      int
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// Command returns the serve command.
+func (s *serve) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run a mock identity provider.",
+		Long: `Run a mock OAuth 2.0 and OpenID Connect identity provider for local
+development and testing. It serves:
+
+  /.well-known/openid-configuration  the provider metadata
+  /jwks                              the JSON Web Key Set
+  /token                             the token endpoint, for the
+                                     client_credentials and password grants
+  /introspect                        the RFC 7662 introspection endpoint
+
+Clients and users are read from the --config file:
+
+  clients:
+    - id: my-service
+      secret: my-secret
+      scopes: [read, write]
+      audience: [https://api.example.com]
+      claims: {tenant: acme}
+  users:
+    - username: alice
+      password: alice
+      subject: 7d1c3b0e
+      scopes: [read]
+      claims: {roles: [admin]}
+
+The iss, exp, iat, nbf and jti claims are set by the provider, and cannot be
+set in the config file. Tokens are signed with the key in --key-file, or with
+a key generated at startup. Do not use this in production.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.do(cmd, args)
+		},
+	}
+	cmd.Flags().StringVar(&s.addr, "addr", "localhost:8080", "The address to listen on.")
+	cmd.Flags().StringVarP(&s.issuer, "issuer", "i", "", "The issuer URL. Defaults to http:// and the listen address.")
+	cmd.Flags().StringVarP(&s.config, "config", "f", "", "A YAML file with the clients and users.")
+	cmd.Flags().StringVarP(&s.keyFile, "key-file", "K", "", "A file with the key to sign with: a PEM private key, a JWK, or a JWKS. Defaults to a generated key.")
+	cmd.Flags().StringVar(&s.kid, "kid", "", "The key ID. Selects the key from a JWKS. Defaults to the ID in a JWK, or the key's thumbprint.")
+	cmd.Flags().StringVar(&s.alg, "alg", "", "The signing algorithm. Defaults to one suitable for the key, or ES256 for a generated key.")
+	cmd.Flags().StringArrayVarP(&s.audience, "audience", "a", nil, "The audience of tokens for clients without one. Can be specified multiple times.")
+	cmd.Flags().DurationVarP(&s.lifetime, "lifetime", "l", time.Hour, "The lifetime of issued tokens.")
+	return cmd
+}
+
+func (s *serve) do(cmd *cobra.Command, args []string) error {
+	config := &serveConfig{}
+	if s.config != "" {
+		data, err := os.ReadFile(s.config)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return fmt.Errorf("%s: %w", s.config, err)
+		}
+		if err := config.check(); err != nil {
+			return fmt.Errorf("%s: %w", s.config, err)
+		}
+	}
+	key, err := s.signingKey()
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	// The default issuer is derived from the listener, so that it has the
+	// actual port when the address has port 0.
+	issuer := strings.TrimSuffix(s.issuer, "/")
+	if issuer == "" {
+		issuer = "http://" + l.Addr().String()
+	}
+	p := s.newProvider(config, key, issuer)
+	fmt.Fprintf(cmd.ErrOrStderr(), "Serving %s on %s with key %q (%s)\n", issuer, l.Addr(), key.ID, key.Method.Alg())
+	ctx, cancel := signalcontext.WithSignals(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	return webserver.Serve(ctx, &http.Server{Handler: p.handler()}, l)
+}
+
+// newProvider returns the provider for the given clients and users, signing
+// key and issuer.
+func (s *serve) newProvider(config *serveConfig, key *jwt.SigningKey, issuer string) *provider {
+	keys := jwt.NewKeyRing()
+	keys.Add(key)
+	return &provider{
+		config: config,
+		issuer: issuer,
+		keys:   keys,
+		signer: jwt.NewSigner(keys,
+			jwt.WithSignerIssuer(issuer),
+			jwt.WithSignerAudience(s.audience...),
+			jwt.WithSignerLifetime(s.lifetime),
+		),
+		validator: jwt.NewMiddleware(
+			jwt.WithKey(keys),
+			jwt.WithIssuer(issuer),
+			jwt.WithNewClaims(func() gojwt.Claims { return gojwt.MapClaims{} }),
+		),
+		lifetime: s.lifetime,
+	}
+}
+
+// reservedClaims are the claims that the provider sets on every token, and
+// that cannot be set in the config file.
+var reservedClaims = []string{"iss", "exp", "iat", "nbf", "jti"}
+
+// check returns an error if a client or user sets a reserved claim.
+func (c *serveConfig) check() error {
+	for _, client := range c.Clients {
+		for _, name := range reservedClaims {
+			if _, ok := client.Claims[name]; ok {
+				return fmt.Errorf("client %q: the %s claim cannot be set", client.ID, name)
+			}
+		}
+	}
+	for _, user := range c.Users {
+		for _, name := range reservedClaims {
+			if _, ok := user.Claims[name]; ok {
+				return fmt.Errorf("user %q: the %s claim cannot be set", user.Username, name)
+			}
+		}
+	}
+	return nil
+}
+
+// signingKey returns the key to sign tokens with.
+func (s *serve) signingKey() (*jwt.SigningKey, error) {
+	if s.keyFile == "" {
+		alg := s.alg
+		if alg == "" {
+			alg = "ES256"
+		}
+		key, err := jwt.GenerateSigningKey(alg)
+		if err != nil {
+			return nil, err
+		}
+		if s.kid != "" {
+			key.ID = s.kid
+		}
+		return key, nil
+	}
+	key, kid, method, err := signingKey(s.keyFile, "", s.kid, s.alg)
+	if err != nil {
+		return nil, err
+	}
+	if kid == "" {
+		public := key
+		if signer, ok := key.(crypto.Signer); ok {
+			public = signer.Public()
+		}
+		jwk, err := jwt.NewJWK(public)
+		if err != nil {
+			if jwk, err = jwt.NewPrivateJWK(key); err != nil {
+				return nil, err
+			}
+		}
+		if kid, err = jwk.Thumbprint(); err != nil {
+			return nil, err
+		}
+	}
+	return &jwt.SigningKey{ID: kid, Method: method, Key: key}, nil
+}
+
+// provider is the mock identity provider.
+type provider struct {
+	config    *serveConfig
+	issuer    string
+	keys      *jwt.KeyRing
+	signer    *jwt.Signer
+	validator *jwt.Middleware
+	lifetime  time.Duration
+}
+
+// handler returns the provider's HTTP handler.
+func (p *provider) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.Handle("/jwks", jwt.NewJWKSHandler(p.keys))
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("POST /introspect", p.introspect)
+	return mux
+}
+
+// discovery serves the provider metadata.
+func (p *provider) discovery(w http.ResponseWriter, r *http.Request) {
+	algs := []string{}
+	for _, key := range p.keys.VerificationKeys() {
+		if alg := key.Method.Alg(); !slices.Contains(algs, alg) {
+			algs = append(algs, alg)
+		}
+	}
+	writeJSON(w, http.StatusOK, &discoveryDocument{
+		ProviderMetadata: jwt.ProviderMetadata{
+			Issuer:                           p.issuer,
+			JWKSURI:                          p.issuer + "/jwks",
+			TokenEndpoint:                    p.issuer + "/token",
+			IDTokenSigningAlgValuesSupported: algs,
+		},
+		IntrospectionEndpoint:             p.issuer + "/introspect",
+		GrantTypesSupported:               []string{"client_credentials", "password"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+// token serves the token endpoint.
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, &tokenError{http.StatusBadRequest, "invalid_request", err.Error()})
+		return
+	}
+	client, tokErr := p.authenticateClient(r)
+	if tokErr != nil {
+		writeTokenError(w, tokErr)
+		return
+	}
+	claims := gojwt.MapClaims{}
+	var allowed []string
+	switch grant := r.PostForm.Get("grant_type"); grant {
+	case "client_credentials":
+		if client.Secret == "" {
+			writeTokenError(w, &tokenError{http.StatusBadRequest, "unauthorized_client", "public clients cannot use the client_credentials grant"})
+			return
+		}
+		for k, v := range client.Claims {
+			claims[k] = v
+		}
+		claims["sub"] = client.ID
+		allowed = client.Scopes
+	case "password":
+		user := p.user(r.PostForm.Get("username"), r.PostForm.Get("password"))
+		if user == nil {
+			writeTokenError(w, &tokenError{http.StatusBadRequest, "invalid_grant", "invalid username or password"})
+			return
+		}
+		for k, v := range client.Claims {
+			claims[k] = v
+		}
+		for k, v := range user.Claims {
+			claims[k] = v
+		}
+		claims["sub"] = user.Subject
+		if user.Subject == "" {
+			claims["sub"] = user.Username
+		}
+		allowed = user.Scopes
+	case "":
+		writeTokenError(w, &tokenError{http.StatusBadRequest, "invalid_request", "grant_type is required"})
+		return
+	default:
+		writeTokenError(w, &tokenError{http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("grant type %q is not supported", grant)})
+		return
+	}
+
+	scopes := allowed
+	if requested := strings.Fields(r.PostForm.Get("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !slices.Contains(allowed, scope) {
+				writeTokenError(w, &tokenError{http.StatusBadRequest, "invalid_scope", fmt.Sprintf("scope %q is not allowed", scope)})
+				return
+			}
+		}
+		scopes = requested
+	}
+	scope := strings.Join(scopes, " ")
+	if scope != "" {
+		claims["scope"] = scope
+	}
+	claims["client_id"] = client.ID
+	if len(client.Audience) > 0 {
+		claims["aud"] = client.Audience
+	}
+
+	token, err := p.signer.Sign(claims)
+	if err != nil {
+		writeTokenError(w, &tokenError{http.StatusInternalServerError, "server_error", err.Error()})
+		return
+	}
+	resp := map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+	}
+	if p.lifetime > 0 {
+		resp["expires_in"] = int(p.lifetime.Seconds())
+	}
+	if scope != "" {
+		resp["scope"] = scope
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// introspect serves the introspection endpoint. Only confidential clients may
+// introspect tokens.
+func (p *provider) introspect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, &tokenError{http.StatusBadRequest, "invalid_request", err.Error()})
+		return
+	}
+	client, tokErr := p.authenticateClient(r)
+	if tokErr != nil {
+		writeTokenError(w, tokErr)
+		return
+	}
+	if client.Secret == "" {
+		writeTokenError(w, &tokenError{http.StatusUnauthorized, "invalid_client", "public clients cannot introspect tokens"})
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeTokenError(w, &tokenError{http.StatusBadRequest, "invalid_request", "token is required"})
+		return
+	}
+	tok, err := p.validator.Validate(token)
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"active": false})
+		return
+	}
+	resp := map[string]interface{}{}
+	for k, v := range tok.Claims.(gojwt.MapClaims) {
+		resp[k] = v
+	}
+	resp["active"] = true
+	resp["token_type"] = "Bearer"
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// authenticateClient authenticates the client of a request, with HTTP Basic
+// authentication or the client_id and client_secret form parameters.
+func (p *provider) authenticateClient(r *http.Request) (*serveClient, *tokenError) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749, section 2.3.1: the credentials are form-encoded.
+		var err error
+		if id, err = url.QueryUnescape(id); err != nil {
+			return nil, &tokenError{http.StatusBadRequest, "invalid_request", "malformed client credentials"}
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return nil, &tokenError{http.StatusBadRequest, "invalid_request", "malformed client credentials"}
+		}
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id == "" {
+		return nil, &tokenError{http.StatusUnauthorized, "invalid_client", "client authentication is required"}
+	}
+	for i := range p.config.Clients {
+		client := &p.config.Clients[i]
+		if client.ID == id && subtle.ConstantTimeCompare([]byte(client.Secret), []byte(secret)) == 1 {
+			return client, nil
+		}
+	}
+	return nil, &tokenError{http.StatusUnauthorized, "invalid_client", "client authentication failed"}
+}
+
+// user returns the user with the given credentials, or nil if there is none.
+func (p *provider) user(username, password string) *serveUser {
+	for i := range p.config.Users {
+		user := &p.config.Users[i]
+		if user.Username == username && subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1 {
+			return user
+		}
+	}
+	return nil
+}
+
+// writeTokenError writes an OAuth 2.0 error response.
+func writeTokenError(w http.ResponseWriter, err *tokenError) {
+	if err.status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="jwtool"`)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, err.status, err)
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smxlong/kit/jwt"
+)
+
+// testServeConfig is the clients and users of the test provider.
+var testServeConfig = &serveConfig{
+	Clients: []serveClient{
+		{
+			ID:       "service",
+			Secret:   "s3cret",
+			Scopes:   []string{"read", "write"},
+			Audience: []string{"https://api.example.com"},
+			Claims:   map[string]interface{}{"tenant": "acme"},
+		},
+		{ID: "spa"},
+	},
+	Users: []serveUser{
+		{
+			Username: "alice",
+			Password: "alice",
+			Subject:  "7d1c3b0e",
+			Scopes:   []string{"read"},
+			Claims:   map[string]interface{}{"roles": []interface{}{"admin"}},
+		},
+	},
+}
+
+// newTestServer starts the provider on a test server. As with the serve
+// command, the issuer is derived from the listener's address.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	key, err := jwt.GenerateSigningKey("ES256")
+	assert.NoError(t, err)
+	server := httptest.NewUnstartedServer(nil)
+	s := &serve{lifetime: time.Hour}
+	server.Config.Handler = s.newProvider(testServeConfig, key, "http://"+server.Listener.Addr().String()).handler()
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// postForm posts a form to the test server, with HTTP Basic authentication if
+// id is not empty, and decodes the JSON response.
+func postForm(t *testing.T, server *httptest.Server, path, id, secret string, form url.Values) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(form.Encode()))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id != "" {
+		req.SetBasicAuth(id, secret)
+	}
+	resp, err := server.Client().Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body := map[string]interface{}{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
+
+func Test_That_serve_Publishes_Discovery_Metadata(t *testing.T) {
+	server := newTestServer(t)
+
+	md, err := jwt.Discover(context.Background(), server.Client(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL, md.Issuer)
+	assert.Equal(t, server.URL+"/jwks", md.JWKSURI)
+	assert.Equal(t, server.URL+"/token", md.TokenEndpoint)
+	assert.Equal(t, []string{"ES256"}, md.IDTokenSigningAlgValuesSupported)
+}
+
+func Test_That_serve_Issues_Tokens_For_The_Client_Credentials_Grant(t *testing.T) {
+	server := newTestServer(t)
+
+	status, body := postForm(t, server, "/token", "service", "s3cret", url.Values{"grant_type": {"client_credentials"}, "scope": {"read"}})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Bearer", body["token_type"])
+	assert.Equal(t, "read", body["scope"])
+	assert.Equal(t, float64(3600), body["expires_in"])
+
+	m := jwt.NewMiddleware(
+		jwt.WithOIDCDiscovery(server.URL),
+		jwt.WithAudience("https://api.example.com"),
+		jwt.WithNewClaims(func() gojwt.Claims { return gojwt.MapClaims{} }),
+	)
+	tok, err := m.Validate(body["access_token"].(string))
+	assert.NoError(t, err)
+	claims := tok.Claims.(gojwt.MapClaims)
+	assert.Equal(t, "service", claims["sub"])
+	assert.Equal(t, "service", claims["client_id"])
+	assert.Equal(t, "acme", claims["tenant"])
+	assert.Equal(t, server.URL, claims["iss"])
+
+	status, body = postForm(t, server, "/token", "service", "s3cret", url.Values{"grant_type": {"client_credentials"}, "scope": {"admin"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_scope", body["error"])
+
+	status, body = postForm(t, server, "/token", "", "", url.Values{"grant_type": {"client_credentials"}, "client_id": {"spa"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "unauthorized_client", body["error"])
+}
+
+func Test_That_serve_Issues_Tokens_For_The_Password_Grant(t *testing.T) {
+	server := newTestServer(t)
+
+	status, body := postForm(t, server, "/token", "", "", url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"spa"},
+		"username":   {"alice"},
+		"password":   {"alice"},
+	})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "read", body["scope"])
+	claims := gojwt.MapClaims{}
+	_, _, err := gojwt.NewParser().ParseUnverified(body["access_token"].(string), claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "7d1c3b0e", claims["sub"])
+	assert.Equal(t, "spa", claims["client_id"])
+	assert.Equal(t, []interface{}{"admin"}, claims["roles"])
+
+	status, body = postForm(t, server, "/token", "", "", url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"spa"},
+		"username":   {"alice"},
+		"password":   {"wrong"},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", body["error"])
+}
+
+func Test_That_serve_Rejects_Bad_Client_Authentication(t *testing.T) {
+	server := newTestServer(t)
+	form := url.Values{"grant_type": {"client_credentials"}}
+
+	for _, tc := range []struct {
+		name       string
+		id, secret string
+		form       url.Values
+	}{
+		{"no credentials", "", "", form},
+		{"wrong secret", "service", "wrong", form},
+		{"unknown client", "other", "s3cret", form},
+		{"wrong form secret", "", "", url.Values{"grant_type": {"client_credentials"}, "client_id": {"service"}, "client_secret": {"wrong"}}},
+	} {
+		status, body := postForm(t, server, "/token", tc.id, tc.secret, tc.form)
+		assert.Equal(t, http.StatusUnauthorized, status, tc.name)
+		assert.Equal(t, "invalid_client", body["error"], tc.name)
+	}
+}
+
+func Test_That_serve_Introspects_Active_And_Inactive_Tokens(t *testing.T) {
+	server := newTestServer(t)
+	_, body := postForm(t, server, "/token", "service", "s3cret", url.Values{"grant_type": {"client_credentials"}})
+	token := body["access_token"].(string)
+
+	status, body := postForm(t, server, "/introspect", "service", "s3cret", url.Values{"token": {token}})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, body["active"])
+	assert.Equal(t, "service", body["sub"])
+	assert.Equal(t, "read write", body["scope"])
+
+	for _, inactive := range []string{"opaque", token + "x"} {
+		status, body = postForm(t, server, "/introspect", "service", "s3cret", url.Values{"token": {inactive}})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, map[string]interface{}{"active": false}, body)
+	}
+}
+
+func Test_That_serve_Requires_A_Confidential_Client_To_Introspect(t *testing.T) {
+	server := newTestServer(t)
+	_, body := postForm(t, server, "/token", "service", "s3cret", url.Values{"grant_type": {"client_credentials"}})
+	token := body["access_token"].(string)
+
+	status, body := postForm(t, server, "/introspect", "", "", url.Values{"token": {token}, "client_id": {"spa"}})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_client", body["error"])
+
+	status, body = postForm(t, server, "/introspect", "", "", url.Values{"token": {token}})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_client", body["error"])
+}
+
+func Test_That_serveConfig_Rejects_Reserved_Claims(t *testing.T) {
+	for _, name := range reservedClaims {
+		config := &serveConfig{Clients: []serveClient{{ID: "service", Claims: map[string]interface{}{name: "x"}}}}
+		assert.ErrorContains(t, config.check(), name)
+		config = &serveConfig{Users: []serveUser{{Username: "alice", Claims: map[string]interface{}{name: "x"}}}}
+		assert.ErrorContains(t, config.check(), name)
+	}
+	assert.NoError(t, testServeConfig.check())
+}
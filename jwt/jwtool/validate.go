@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/cobra"
+
+	"github.com/smxlong/kit/jwt"
+)
+
+// Exit codes of the validate command, one per class of failure. A token that
+// fails several checks exits with the code of the first failed check.
+const (
+	exitMalformed    = 2 // the token could not be parsed
+	exitSignature    = 3 // the signature is invalid or could not be verified
+	exitExpired      = 4 // the token is expired
+	exitNotValidYet  = 5 // the token is not valid yet
+	exitAudience     = 6 // the audience is not accepted
+	exitIssuer       = 7 // the issuer is not accepted
+	exitMissingClaim = 8 // a required claim is missing
+	exitInvalid      = 9 // the token is invalid for another reason
+)
+
+// validate is the validate command.
+type validate struct {
+	key            string        // key to verify with
+	keyFile        string        // file or JWKS URL with the key to verify with
+	token          string        // token to validate
+	audiences      []string      // accepted audiences
+	issuers        []string      // accepted issuers
+	algs           []string      // accepted signing algorithms
+	requiredClaims []string      // claims that must be present
+	leeway         time.Duration // clock skew allowance
+	output         string        // "text" or "json"
+}
+
+// check is the outcome of one validation check.
+type check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// report is the outcome of validating a token.
+type report struct {
+	Valid  bool                   `json:"valid"`
+	Checks []check                `json:"checks"`
+	Header map[string]interface{} `json:"header,omitempty"`
+	Claims gojwt.Claims           `json:"claims,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// checkClass is a class of validation failure.
+type checkClass struct {
+	name     string
+	errs     []error
+	exitCode int
+	// blocking is true if claims are not validated when this check fails.
+	blocking bool
+}
+
+// checkClasses are the classes of validation failure, in the order they are
+// checked.
+var checkClasses = []checkClass{
+	{"format", []error{gojwt.ErrTokenMalformed}, exitMalformed, true},
+	{"signature", []error{gojwt.ErrTokenSignatureInvalid, gojwt.ErrTokenUnverifiable, jwt.ErrDecryptionFailed}, exitSignature, true},
+	{"expiry", []error{gojwt.ErrTokenExpired}, exitExpired, false},
+	{"not before", []error{gojwt.ErrTokenNotValidYet, gojwt.ErrTokenUsedBeforeIssued}, exitNotValidYet, false},
+	{"audience", []error{gojwt.ErrTokenInvalidAudience}, exitAudience, false},
+	{"issuer", []error{gojwt.ErrTokenInvalidIssuer}, exitIssuer, false},
+	{"required claims", []error{gojwt.ErrTokenRequiredClaimMissing}, exitMissingClaim, false},
+}
+
+// Command returns the validate command.
+func (v *validate) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate a JWT.",
+		Long: `Validate a JWT as jwt.Middleware would, and report the outcome of each check.
+
+The exit code is 0 if the token is valid, and otherwise identifies the first
+failed check: 2 if the token is malformed, 3 if the signature is invalid, 4 if
+the token is expired, 5 if it is not valid yet, 6 if the audience is not
+accepted, 7 if the issuer is not accepted, 8 if a required claim is missing,
+and 9 for any other reason.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return v.do(cmd, args)
+		},
+	}
+	cmd.Flags().StringVarP(&v.key, "key", "k", "", "The key to verify with.")
+	cmd.Flags().StringVarP(&v.keyFile, "key-file", "K", "", "A file with the key to verify with: a PEM key or certificate, a JWK, a JWKS, or a shared secret. May also be the URL of a JWKS.")
+	cmd.Flags().StringVarP(&v.token, "token", "t", "", "The token to validate. If not given, token will be read from stdin.")
+	cmd.Flags().StringArrayVarP(&v.audiences, "audience", "a", nil, "An accepted audience. Can be specified multiple times.")
+	cmd.Flags().StringArrayVarP(&v.issuers, "issuer", "i", nil, "An accepted issuer. Can be specified multiple times.")
+	cmd.Flags().StringArrayVar(&v.algs, "valid-alg", nil, "An accepted signing algorithm. Can be specified multiple times.")
+	cmd.Flags().StringArrayVar(&v.requiredClaims, "require", nil, "A claim that must be present. Can be specified multiple times.")
+	cmd.Flags().DurationVarP(&v.leeway, "leeway", "l", 0, "The allowance for clock skew when checking exp, nbf and iat.")
+	cmd.Flags().StringVarP(&v.output, "output", "o", "text", "The report format: \"text\" or \"json\".")
+	return cmd
+}
+
+func (v *validate) do(cmd *cobra.Command, args []string) error {
+	if v.output != "text" && v.output != "json" {
+		return fmt.Errorf("unknown output format %q", v.output)
+	}
+	if v.token == "" {
+		scanner := bufio.NewScanner(cmd.InOrStdin())
+		if !scanner.Scan() {
+			return errors.New("no token given")
+		}
+		v.token = scanner.Text()
+	}
+
+	opts, err := v.options()
+	if err != nil {
+		return err
+	}
+	m := jwt.NewMiddleware(opts...)
+	tok, err := m.Validate(v.token)
+	r, code := newReport(tok, err)
+	if err := r.write(cmd.OutOrStdout(), v.output); err != nil {
+		return err
+	}
+	if code != 0 {
+		return &exitError{code: code}
+	}
+	return nil
+}
+
+// options returns the middleware options for the command's flags.
+func (v *validate) options() ([]jwt.Option, error) {
+	keyfunc, err := verificationKeyfunc(v.keyFile, v.key)
+	if err != nil {
+		return nil, err
+	}
+	opts := []jwt.Option{
+		jwt.WithNewClaims(func() gojwt.Claims { return gojwt.MapClaims{} }),
+		jwt.WithKey(keyfunc),
+		jwt.WithLeeway(v.leeway),
+	}
+	if len(v.audiences) > 0 {
+		opts = append(opts, jwt.WithAudiences(v.audiences...))
+	}
+	if len(v.issuers) > 0 {
+		opts = append(opts, jwt.WithIssuers(v.issuers...))
+	}
+	if len(v.algs) > 0 {
+		opts = append(opts, jwt.WithValidMethods(v.algs...))
+	}
+	if len(v.requiredClaims) > 0 {
+		opts = append(opts, jwt.WithRequiredClaims(v.requiredClaims...))
+	}
+	return opts, nil
+}
+
+// newReport returns the report for a validated token, and the exit code.
+func newReport(tok *gojwt.Token, err error) (*report, int) {
+	r := &report{Valid: err == nil}
+	if tok != nil {
+		r.Header = tok.Header
+		r.Claims = tok.Claims
+	}
+	code := 0
+	skip := false
+	for _, class := range checkClasses {
+		c := check{Name: class.name, Status: "ok"}
+		switch {
+		case skip:
+			c.Status = "skipped"
+		case matches(err, class.errs):
+			c.Status = "failed"
+			if code == 0 {
+				code = class.exitCode
+			}
+			skip = class.blocking
+		}
+		r.Checks = append(r.Checks, c)
+	}
+	if err != nil {
+		r.Error = err.Error()
+		if code == 0 {
+			code = exitInvalid
+		}
+	}
+	return r, code
+}
+
+// matches returns true if err is any of the targets.
+func matches(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes the report in the given format.
+func (r *report) write(w io.Writer, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	for _, c := range r.Checks {
+		fmt.Fprintf(w, "%-16s %s\n", c.Name, c.Status)
+	}
+	if r.Valid {
+		fmt.Fprintln(w, "\ntoken is valid")
+	} else {
+		fmt.Fprintf(w, "\ntoken is invalid: %s\n", r.Error)
+	}
+	return nil
+}
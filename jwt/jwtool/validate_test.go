@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smxlong/kit/jwt"
+)
+
+// invalidClaims wraps a claims error as the gojwt parser does.
+func invalidClaims(err error) error {
+	return fmt.Errorf("%w: %w", gojwt.ErrTokenInvalidClaims, err)
+}
+
+func Test_That_newReport_Maps_Errors_To_Checks_And_Exit_Codes(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		err    error
+		code   int
+		failed []string
+	}{
+		{"valid", nil, 0, nil},
+		{"malformed", fmt.Errorf("%w: bad segment", gojwt.ErrTokenMalformed), 2, []string{"format"}},
+		{"signature", fmt.Errorf("%w: %w", gojwt.ErrTokenSignatureInvalid, errors.New("crypto")), 3, []string{"signature"}},
+		{"unverifiable", fmt.Errorf("%w: %w", gojwt.ErrTokenUnverifiable, errors.New("no key")), 3, []string{"signature"}},
+		{"decryption", fmt.Errorf("%w: %w", jwt.ErrDecryptionFailed, errors.New("bad tag")), 3, []string{"signature"}},
+		{"expired", invalidClaims(gojwt.ErrTokenExpired), 4, []string{"expiry"}},
+		{"not valid yet", invalidClaims(gojwt.ErrTokenNotValidYet), 5, []string{"not before"}},
+		{"used before issued", invalidClaims(gojwt.ErrTokenUsedBeforeIssued), 5, []string{"not before"}},
+		{"audience", invalidClaims(gojwt.ErrTokenInvalidAudience), 6, []string{"audience"}},
+		{"issuer", invalidClaims(gojwt.ErrTokenInvalidIssuer), 7, []string{"issuer"}},
+		{"missing claim", invalidClaims(fmt.Errorf("%w: tid claim is required", gojwt.ErrTokenRequiredClaimMissing)), 8, []string{"required claims"}},
+		{"other", errors.New("token has been revoked"), 9, nil},
+		{"joined", errors.Join(
+			invalidClaims(gojwt.ErrTokenInvalidIssuer),
+			invalidClaims(gojwt.ErrTokenExpired),
+			invalidClaims(gojwt.ErrTokenInvalidAudience),
+		), 4, []string{"expiry", "audience", "issuer"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, code := newReport(nil, tc.err)
+			assert.Equal(t, tc.code, code)
+			assert.Equal(t, tc.err == nil, r.Valid)
+			var failed []string
+			for _, c := range r.Checks {
+				if c.Status == "failed" {
+					failed = append(failed, c.Name)
+				}
+			}
+			assert.Equal(t, tc.failed, failed)
+			if tc.err != nil {
+				assert.Equal(t, tc.err.Error(), r.Error)
+			}
+		})
+	}
+}
+
+func Test_That_newReport_Skips_Claim_Checks_After_A_Blocking_Failure(t *testing.T) {
+	r, code := newReport(nil, errors.Join(gojwt.ErrTokenSignatureInvalid, gojwt.ErrTokenExpired))
+	assert.Equal(t, 3, code)
+	assert.Equal(t, []check{
+		{Name: "format", Status: "ok"},
+		{Name: "signature", Status: "failed"},
+		{Name: "expiry", Status: "skipped"},
+		{Name: "not before", Status: "skipped"},
+		{Name: "audience", Status: "skipped"},
+		{Name: "issuer", Status: "skipped"},
+		{Name: "required claims", Status: "skipped"},
+	}, r.Checks)
+}
+
+// runValidate runs the validate command with the given arguments and returns
+// its JSON report and exit code.
+func runValidate(t *testing.T, args ...string) (*report, int) {
+	t.Helper()
+	var out bytes.Buffer
+	cmd := (&validate{}).Command()
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(append(args, "--output", "json"))
+	code := 0
+	if err := cmd.Execute(); err != nil {
+		var exit *exitError
+		if !assert.ErrorAs(t, err, &exit) {
+			return nil, 0
+		}
+		code = exit.code
+	}
+	r := &report{Claims: &gojwt.MapClaims{}}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), r))
+	return r, code
+}
+
+func Test_That_validate_Reports_Signed_Tokens(t *testing.T) {
+	dir := t.TempDir()
+	private, public := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub.pem")
+	_, err := runKeygen("--type", "ec", "--out", private, "--public-out", public)
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		encode []string
+		code   int
+		failed string
+	}{
+		{"valid", nil, 0, ""},
+		{"expired", []string{"--expires-in=-1h"}, exitExpired, "expiry"},
+		{"wrong audience", []string{"--audience", "https://other.example.com"}, exitAudience, "audience"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := runEncode(append([]string{"--key-file", private}, tc.encode...)...)
+			assert.NoError(t, err)
+
+			r, code := runValidate(t, "--key-file", public, "--token", token,
+				"--audience", "https://example.com", "--issuer", "https://example.com")
+			assert.Equal(t, tc.code, code)
+			assert.Equal(t, tc.code == 0, r.Valid)
+			for _, c := range r.Checks {
+				if c.Name == tc.failed {
+					assert.Equal(t, "failed", c.Status, c.Name)
+				} else {
+					assert.Equal(t, "ok", c.Status, c.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrNoSigningKey is returned when a KeyRing has no active key and cannot
+	// generate one.
+	ErrNoSigningKey = errors.New("no signing key")
+)
+
+// SigningKey is a key used to sign tokens.
+type SigningKey struct {
+	// ID is the key ID, used as the kid header of signed tokens.
+	ID string
+	// Method is the signing method.
+	Method gojwt.SigningMethod
+	// Key is the private key, or the secret for HMAC methods.
+	Key interface{}
+}
+
+// GenerateSigningKey generates a new key for the given algorithm, such as
+// "HS256", "RS256", "PS256", "ES256" or "EdDSA", with a random ID.
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	method := gojwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	var key interface{}
+	var err error
+	switch alg {
+	case "HS256":
+		key, err = randomBytes(32)
+	case "HS384":
+		key, err = randomBytes(48)
+	case "HS512":
+		key, err = randomBytes(64)
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		key, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "EdDSA":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{ID: id, Method: method, Key: key}, nil
+}
+
+// VerificationKey returns the key used to verify tokens signed with this key:
+// the public key, or the secret for HMAC methods.
+func (k *SigningKey) VerificationKey() interface{} {
+	if signer, ok := k.Key.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return k.Key
+}
+
+// KeyRing is a set of signing keys. One key is active and used for signing.
+// When the active key is rotated, the previous key is retired: it is no
+// longer used for signing, but remains available for verification for the
+// retention period, so that tokens it signed stay valid.
+//
+// A KeyRing implements KeySource, so it can be passed directly to WithKey.
+type KeyRing struct {
+	generate  func() (*SigningKey, error)
+	rotation  time.Duration
+	retention time.Duration
+	now       func() time.Time
+
+	mu   sync.Mutex
+	keys []*ringKey
+}
+
+// ringKey is a key in a KeyRing. The last key in the ring is active.
+type ringKey struct {
+	key       *SigningKey
+	activated time.Time
+	retired   time.Time
+}
+
+// KeyRingOption is an option for a KeyRing.
+type KeyRingOption func(*KeyRing)
+
+// WithKeyRingAlgorithm makes the KeyRing generate keys for the given
+// algorithm, with GenerateSigningKey, when it needs a new active key.
+func WithKeyRingAlgorithm(alg string) KeyRingOption {
+	return WithKeyRingGenerator(func() (*SigningKey, error) {
+		return GenerateSigningKey(alg)
+	})
+}
+
+// WithKeyRingGenerator sets the function the KeyRing uses to generate a new
+// active key.
+func WithKeyRingGenerator(generate func() (*SigningKey, error)) KeyRingOption {
+	return func(kr *KeyRing) {
+		kr.generate = generate
+	}
+}
+
+// WithKeyRingRotation sets how often the active key is replaced with a newly
+// generated one. The default is zero, meaning keys are only rotated when
+// Rotate or Add is called.
+func WithKeyRingRotation(interval time.Duration) KeyRingOption {
+	return func(kr *KeyRing) {
+		kr.rotation = interval
+	}
+}
+
+// WithKeyRingRetention sets how long retired keys remain available for
+// verification. This should be at least the lifetime of the tokens signed. The
+// default is 24 hours.
+func WithKeyRingRetention(retention time.Duration) KeyRingOption {
+	return func(kr *KeyRing) {
+		kr.retention = retention
+	}
+}
+
+// NewKeyRing returns an empty KeyRing. Keys are added with Add, or generated
+// on first use if the KeyRing was created WithKeyRingAlgorithm or
+// WithKeyRingGenerator.
+func NewKeyRing(opts ...KeyRingOption) *KeyRing {
+	kr := &KeyRing{
+		retention: 24 * time.Hour,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(kr)
+	}
+	return kr
+}
+
+// Add adds a key to the ring and makes it the active key, retiring the
+// previously active key.
+func (kr *KeyRing) Add(key *SigningKey) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.add(key)
+}
+
+// Rotate generates a new active key, retiring the previously active key.
+func (kr *KeyRing) Rotate() (*SigningKey, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	return kr.rotate()
+}
+
+// Active returns the active key, rotating it first if it is due.
+func (kr *KeyRing) Active() (*SigningKey, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.prune()
+	if len(kr.keys) == 0 {
+		return kr.rotate()
+	}
+	active := kr.keys[len(kr.keys)-1]
+	if kr.rotation > 0 && kr.generate != nil && !kr.now().Before(active.activated.Add(kr.rotation)) {
+		return kr.rotate()
+	}
+	return active.key, nil
+}
+
+// NextRotation returns the time at which the active key will be rotated, or
+// the zero time if keys are not rotated on a schedule.
+func (kr *KeyRing) NextRotation() time.Time {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if kr.rotation <= 0 || kr.generate == nil || len(kr.keys) == 0 {
+		return time.Time{}
+	}
+	return kr.keys[len(kr.keys)-1].activated.Add(kr.rotation)
+}
+
+// VerificationKeys returns the active key and the retired keys that are still
+// within their retention period, newest first.
+func (kr *KeyRing) VerificationKeys() []*SigningKey {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.prune()
+	keys := make([]*SigningKey, 0, len(kr.keys))
+	for i := len(kr.keys) - 1; i >= 0; i-- {
+		keys = append(keys, kr.keys[i].key)
+	}
+	return keys
+}
+
+// Keyfunc implements KeySource. The key is selected by the token's kid header
+// from the verification keys.
+func (kr *KeyRing) Keyfunc(token *gojwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range kr.VerificationKeys() {
+		if key.ID != kid {
+			continue
+		}
+		if key.Method.Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("key %q is not for use with %s", kid, token.Method.Alg())
+		}
+		return key.VerificationKey(), nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// add adds a key as the active key. The caller must hold kr.mu.
+func (kr *KeyRing) add(key *SigningKey) {
+	now := kr.now()
+	if len(kr.keys) > 0 {
+		kr.keys[len(kr.keys)-1].retired = now
+	}
+	kr.keys = append(kr.keys, &ringKey{key: key, activated: now})
+}
+
+// rotate generates and adds a new active key. The caller must hold kr.mu.
+func (kr *KeyRing) rotate() (*SigningKey, error) {
+	if kr.generate == nil {
+		return nil, ErrNoSigningKey
+	}
+	key, err := kr.generate()
+	if err != nil {
+		return nil, err
+	}
+	kr.add(key)
+	return key, nil
+}
+
+// prune removes retired keys whose retention period has passed. The caller
+// must hold kr.mu.
+func (kr *KeyRing) prune() {
+	now := kr.now()
+	keys := kr.keys[:0]
+	for _, k := range kr.keys {
+		if k.retired.IsZero() || now.Before(k.retired.Add(kr.retention)) {
+			keys = append(keys, k)
+		}
+	}
+	kr.keys = keys
+}
+
+// randomBytes returns n random bytes.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// randomID returns a random identifier suitable for a kid or jti.
+func randomID() (string, error) {
+	b, err := randomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,98 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_GenerateSigningKey_Generates_Keys_For_Each_Algorithm(t *testing.T) {
+	for _, alg := range []string{"HS256", "HS384", "HS512", "RS256", "PS256", "ES256", "ES384", "ES512", "EdDSA"} {
+		key, err := GenerateSigningKey(alg)
+		assert.NoError(t, err, alg)
+		assert.Equal(t, alg, key.Method.Alg())
+		assert.NotEmpty(t, key.ID)
+
+		token, err := gojwt.New(key.Method).SignedString(key.Key)
+		assert.NoError(t, err, alg)
+		_, err = gojwt.Parse(token, func(*gojwt.Token) (interface{}, error) {
+			return key.VerificationKey(), nil
+		})
+		assert.NoError(t, err, alg)
+	}
+	_, err := GenerateSigningKey("none")
+	assert.Error(t, err)
+}
+
+func Test_That_KeyRing_Without_A_Generator_Has_No_Active_Key(t *testing.T) {
+	_, err := NewKeyRing().Active()
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+}
+
+func Test_That_KeyRing_Rotates_On_Schedule_And_Retains_Retired_Keys(t *testing.T) {
+	now := time.Now()
+	kr := NewKeyRing(
+		WithKeyRingAlgorithm("ES256"),
+		WithKeyRingRotation(time.Hour),
+		WithKeyRingRetention(30*time.Minute),
+	)
+	kr.now = func() time.Time { return now }
+
+	first, err := kr.Active()
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), kr.NextRotation())
+
+	now = now.Add(59 * time.Minute)
+	active, err := kr.Active()
+	assert.NoError(t, err)
+	assert.Same(t, first, active)
+
+	now = now.Add(time.Minute)
+	second, err := kr.Active()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Equal(t, []*SigningKey{second, first}, kr.VerificationKeys())
+
+	now = now.Add(30 * time.Minute)
+	assert.Equal(t, []*SigningKey{second}, kr.VerificationKeys())
+}
+
+func Test_That_KeyRing_Add_Retires_The_Active_Key(t *testing.T) {
+	kr := NewKeyRing()
+	first, err := GenerateSigningKey("HS256")
+	assert.NoError(t, err)
+	second, err := GenerateSigningKey("HS256")
+	assert.NoError(t, err)
+
+	kr.Add(first)
+	kr.Add(second)
+
+	active, err := kr.Active()
+	assert.NoError(t, err)
+	assert.Same(t, second, active)
+	assert.Equal(t, []*SigningKey{second, first}, kr.VerificationKeys())
+}
+
+func Test_That_KeyRing_Keyfunc_Verifies_Tokens_Signed_By_Retired_Keys(t *testing.T) {
+	kr := NewKeyRing(WithKeyRingAlgorithm("RS256"))
+	signer := NewSigner(kr)
+	middleware := NewMiddleware(WithKey(kr))
+
+	token, err := signer.Sign(&gojwt.RegisteredClaims{Subject: "test"})
+	assert.NoError(t, err)
+	_, err = kr.Rotate()
+	assert.NoError(t, err)
+
+	claims, err := middleware.parseClaims(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", claims.(*gojwt.RegisteredClaims).Subject)
+
+	unknown := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{})
+	unknown.Header["kid"] = "unknown"
+	token, err = unknown.SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
@@ -0,0 +1,294 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrUnknownKey is returned when no key matches the token's kid header.
+	ErrUnknownKey = errors.New("unknown key")
+)
+
+// fetchTimeout bounds each request this package makes to fetch keys, provider
+// metadata or introspection results.
+const fetchTimeout = 10 * time.Second
+
+// defaultHTTPClient is the HTTP client used when none is set. Unlike
+// http.DefaultClient, it has a timeout.
+var defaultHTTPClient = &http.Client{Timeout: fetchTimeout}
+
+// KeySource is implemented by types that resolve the key used to validate a
+// token, such as KeySet. A KeySource can be passed directly to WithKey.
+type KeySource interface {
+	// Keyfunc returns the key to use to validate the given token.
+	Keyfunc(*gojwt.Token) (interface{}, error)
+}
+
+// KeySet is a JSON Web Key Set loaded from a URL or file. Keys are selected by
+// the token's kid header and cached. The set is reloaded when the cache
+// expires, and when a token refers to an unknown kid, subject to a minimum
+// interval between reloads. Reloads are made without blocking lookups of
+// cached keys, and concurrent reloads are coalesced into one.
+//
+// A KeySet implements KeySource, so it can be passed directly to WithKey.
+type KeySet struct {
+	load               func(context.Context) ([]byte, error)
+	client             *http.Client
+	ttl                time.Duration
+	minRefreshInterval time.Duration
+	now                func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]keySetEntry
+	fetched     time.Time
+	lastRefresh time.Time
+	lastErr     error
+	inflight    *keySetRefresh
+}
+
+// keySetRefresh is a reload of a KeySet in progress. err is set before done
+// is closed.
+type keySetRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// keySetEntry is a key in a KeySet.
+type keySetEntry struct {
+	key       interface{}
+	algorithm string
+}
+
+// KeySetOption is an option for a KeySet.
+type KeySetOption func(*KeySet)
+
+// WithKeySetTTL sets how long keys are cached before the set is reloaded. The
+// default is one hour.
+func WithKeySetTTL(ttl time.Duration) KeySetOption {
+	return func(ks *KeySet) {
+		ks.ttl = ttl
+	}
+}
+
+// WithKeySetMinRefreshInterval sets the minimum time between reloads caused by
+// tokens with an unknown kid, and between retries of a failed reload. The
+// default is one minute.
+func WithKeySetMinRefreshInterval(interval time.Duration) KeySetOption {
+	return func(ks *KeySet) {
+		ks.minRefreshInterval = interval
+	}
+}
+
+// WithKeySetHTTPClient sets the HTTP client used to fetch a key set from a
+// URL. The default is a client with a ten second timeout.
+func WithKeySetHTTPClient(client *http.Client) KeySetOption {
+	return func(ks *KeySet) {
+		ks.client = client
+	}
+}
+
+// NewKeySetFromURL returns a KeySet that loads keys from the given URL. Keys
+// are loaded on first use.
+func NewKeySetFromURL(url string, opts ...KeySetOption) *KeySet {
+	ks := newKeySet(opts...)
+	ks.load = func(ctx context.Context) ([]byte, error) {
+		return fetch(ctx, ks.client, url)
+	}
+	return ks
+}
+
+// NewKeySetFromFile returns a KeySet that loads keys from the given file. Keys
+// are loaded on first use.
+func NewKeySetFromFile(path string, opts ...KeySetOption) *KeySet {
+	ks := newKeySet(opts...)
+	ks.load = func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+	return ks
+}
+
+// newKeySet returns a KeySet with defaults and the given options applied.
+func newKeySet(opts ...KeySetOption) *KeySet {
+	ks := &KeySet{
+		client:             defaultHTTPClient,
+		ttl:                time.Hour,
+		minRefreshInterval: time.Minute,
+		now:                time.Now,
+	}
+	for _, opt := range opts {
+		opt(ks)
+	}
+	return ks
+}
+
+// Keyfunc implements KeySource. The key is selected by the token's kid header.
+// If the token has no kid, the set must contain exactly one key.
+func (ks *KeySet) Keyfunc(token *gojwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	entry, err := ks.lookup(context.Background(), kid)
+	if err != nil {
+		return nil, err
+	}
+	if entry.algorithm != "" && entry.algorithm != token.Method.Alg() {
+		return nil, fmt.Errorf("key %q is not for use with %s", kid, token.Method.Alg())
+	}
+	return entry.key, nil
+}
+
+// Key returns the key with the given kid.
+func (ks *KeySet) Key(ctx context.Context, kid string) (interface{}, error) {
+	entry, err := ks.lookup(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+	return entry.key, nil
+}
+
+// Refresh reloads the key set.
+func (ks *KeySet) Refresh(ctx context.Context) error {
+	return ks.refresh(ctx)
+}
+
+// lookup finds the key with the given kid, reloading the set if the cache has
+// expired or the kid is unknown. Reloads are at least the minimum refresh
+// interval apart, except while no keys have been loaded.
+func (ks *KeySet) lookup(ctx context.Context, kid string) (keySetEntry, error) {
+	ks.mu.Lock()
+	now := ks.now()
+	due := now.Sub(ks.lastRefresh) >= ks.minRefreshInterval
+	loaded := ks.keys != nil
+	expired := !loaded || now.Sub(ks.fetched) >= ks.ttl
+	lastErr := ks.lastErr
+	ks.mu.Unlock()
+
+	if expired {
+		if !loaded && !due && lastErr != nil {
+			return keySetEntry{}, fmt.Errorf("%w: %w", ErrUnavailable, lastErr)
+		}
+		if loaded && !due {
+			// Keep using the expired keys until a reload is allowed.
+		} else if err := ks.refresh(ctx); err != nil && !loaded {
+			return keySetEntry{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		} else {
+			due = false
+		}
+	}
+	if entry, ok := ks.find(kid); ok {
+		return entry, nil
+	}
+	if !due {
+		return keySetEntry{}, ErrUnknownKey
+	}
+	if err := ks.refresh(ctx); err != nil {
+		return keySetEntry{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	if entry, ok := ks.find(kid); ok {
+		return entry, nil
+	}
+	return keySetEntry{}, ErrUnknownKey
+}
+
+// find returns the key with the given kid. An empty kid matches the only key
+// in a set of one.
+func (ks *KeySet) find(kid string) (keySetEntry, bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if kid == "" && len(ks.keys) == 1 {
+		for _, entry := range ks.keys {
+			return entry, true
+		}
+	}
+	entry, ok := ks.keys[kid]
+	return entry, ok
+}
+
+// refresh reloads the key set, or waits for the reload in progress. The load
+// is not canceled with ctx, so that it can complete for other callers, but it
+// is bounded by fetchTimeout; ctx only bounds the wait.
+func (ks *KeySet) refresh(ctx context.Context) error {
+	ks.mu.Lock()
+	call := ks.inflight
+	if call == nil {
+		call = &keySetRefresh{done: make(chan struct{})}
+		ks.inflight = call
+		ks.lastRefresh = ks.now()
+		go ks.reload(context.WithoutCancel(ctx), call)
+	}
+	ks.mu.Unlock()
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reload loads the key set for a refresh.
+func (ks *KeySet) reload(ctx context.Context, call *keySetRefresh) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	var keys map[string]keySetEntry
+	data, err := ks.load(ctx)
+	if err == nil {
+		keys, err = parseJWKS(data)
+	}
+	ks.mu.Lock()
+	if err == nil {
+		ks.keys = keys
+		ks.fetched = ks.lastRefresh
+	}
+	ks.lastErr = err
+	ks.inflight = nil
+	call.err = err
+	ks.mu.Unlock()
+	close(call.done)
+}
+
+// parseJWKS parses a JSON Web Key Set into a map of keys by kid. Keys that are
+// not for signing, or whose type is not supported, are skipped.
+func parseJWKS(data []byte) (map[string]keySetEntry, error) {
+	var jwks JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("invalid key set: %w", err)
+	}
+	keys := map[string]keySetEntry{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = keySetEntry{key: key, algorithm: jwk.Algorithm}
+	}
+	return keys, nil
+}
+
+// fetch GETs the given URL and returns the response body.
+func fetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
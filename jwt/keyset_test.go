@@ -0,0 +1,265 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// testJWK returns a JWK for the given public key.
+func testJWK(t *testing.T, kid string, key interface{}) JWK {
+	t.Helper()
+	jwk, err := NewJWK(key)
+	assert.NoError(t, err)
+	jwk.KeyID = kid
+	return *jwk
+}
+
+// testKeys holds one key of each supported type.
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ecdsa   *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+// newTestKeys generates one key of each supported type.
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	return &testKeys{rsa: rsaKey, ecdsa: ecdsaKey, ed25519: ed25519Key}
+}
+
+// jwks returns the JWKS document for the keys.
+func (k *testKeys) jwks(t *testing.T) []byte {
+	t.Helper()
+	data, err := json.Marshal(JWKS{Keys: []JWK{
+		testJWK(t, "rsa", &k.rsa.PublicKey),
+		testJWK(t, "ec", &k.ecdsa.PublicKey),
+		testJWK(t, "ed", k.ed25519.Public()),
+	}})
+	assert.NoError(t, err)
+	return data
+}
+
+// signTestToken signs a token with the given method, kid and key.
+func signTestToken(t *testing.T, method gojwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+	tok := gojwt.NewWithClaims(method, &gojwt.RegisteredClaims{Subject: "test"})
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	assert.NoError(t, err)
+	return s
+}
+
+func Test_That_KeySet_Validates_RSA_EC_And_Ed25519_Tokens_From_A_URL(t *testing.T) {
+	keys := newTestKeys(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write(keys.jwks(t))
+	}))
+	defer server.Close()
+
+	middleware := NewMiddleware(WithKey(NewKeySetFromURL(server.URL)))
+
+	for _, token := range []string{
+		signTestToken(t, gojwt.SigningMethodRS256, "rsa", keys.rsa),
+		signTestToken(t, gojwt.SigningMethodES256, "ec", keys.ecdsa),
+		signTestToken(t, gojwt.SigningMethodEdDSA, "ed", keys.ed25519),
+	} {
+		claims, err := middleware.parseClaims(token)
+		assert.NoError(t, err)
+		assert.Equal(t, "test", claims.(*gojwt.RegisteredClaims).Subject)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "keys should be cached")
+}
+
+func Test_That_KeySet_Loads_Keys_From_A_File(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, keys.jwks(t), 0o600))
+
+	middleware := NewMiddleware(WithKey(NewKeySetFromFile(path)))
+
+	_, err := middleware.parseClaims(signTestToken(t, gojwt.SigningMethodES256, "ec", keys.ecdsa))
+	assert.NoError(t, err)
+}
+
+func Test_That_KeySet_Rejects_A_Token_Signed_With_The_Wrong_Key_Type(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, keys.jwks(t), 0o600))
+
+	middleware := NewMiddleware(WithKey(NewKeySetFromFile(path)))
+
+	// Signed with the EC key, but claims to use the RSA key.
+	_, err := middleware.parseClaims(signTestToken(t, gojwt.SigningMethodES256, "rsa", keys.ecdsa))
+	assert.Error(t, err)
+}
+
+func Test_That_KeySet_Refreshes_On_Unknown_Kid_With_Rate_Limiting(t *testing.T) {
+	first := newTestKeys(t)
+	second := newTestKeys(t)
+	var current atomic.Pointer[testKeys]
+	current.Store(first)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		data := current.Load().jwks(t)
+		var jwks JWKS
+		assert.NoError(t, json.Unmarshal(data, &jwks))
+		if current.Load() == second {
+			for i := range jwks.Keys {
+				jwks.Keys[i].KeyID += "2"
+			}
+		}
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	ks := NewKeySetFromURL(server.URL, WithKeySetMinRefreshInterval(time.Minute))
+	ks.now = func() time.Time { return now }
+	middleware := NewMiddleware(WithKey(ks))
+
+	_, err := middleware.parseClaims(signTestToken(t, gojwt.SigningMethodRS256, "rsa", first.rsa))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// Keys rotate. The new kid is unknown, but a refresh was just done.
+	current.Store(second)
+	_, err = middleware.parseClaims(signTestToken(t, gojwt.SigningMethodRS256, "rsa2", second.rsa))
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// After the minimum interval, the unknown kid causes a refresh.
+	now = now.Add(time.Minute)
+	_, err = middleware.parseClaims(signTestToken(t, gojwt.SigningMethodRS256, "rsa2", second.rsa))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func Test_That_KeySet_Refreshes_When_The_TTL_Expires(t *testing.T) {
+	keys := newTestKeys(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write(keys.jwks(t))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	ks := NewKeySetFromURL(server.URL, WithKeySetTTL(time.Hour))
+	ks.now = func() time.Time { return now }
+	middleware := NewMiddleware(WithKey(ks))
+	token := signTestToken(t, gojwt.SigningMethodRS256, "rsa", keys.rsa)
+
+	_, err := middleware.parseClaims(token)
+	assert.NoError(t, err)
+	now = now.Add(59 * time.Minute)
+	_, err = middleware.parseClaims(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	now = now.Add(time.Minute)
+	_, err = middleware.parseClaims(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func Test_That_KeySet_Uses_The_Only_Key_When_The_Token_Has_No_Kid(t *testing.T) {
+	keys := newTestKeys(t)
+	data, err := json.Marshal(JWKS{Keys: []JWK{testJWK(t, "rsa", &keys.rsa.PublicKey)}})
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	middleware := NewMiddleware(WithKey(NewKeySetFromFile(path)))
+
+	_, err = middleware.parseClaims(signTestToken(t, gojwt.SigningMethodRS256, "", keys.rsa))
+	assert.NoError(t, err)
+}
+
+func Test_That_KeySet_Returns_An_Error_When_The_URL_Fails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := NewKeySetFromURL(server.URL).Key(context.Background(), "rsa")
+	assert.Error(t, err)
+}
+
+func Test_That_KeySet_Coalesces_Concurrent_Refreshes(t *testing.T) {
+	keys := newTestKeys(t)
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		_, _ = w.Write(keys.jwks(t))
+	}))
+	defer server.Close()
+
+	ks := NewKeySetFromURL(server.URL)
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := ks.Key(context.Background(), "rsa")
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func Test_That_KeySet_Does_Not_Block_Cached_Lookups_During_A_Refresh(t *testing.T) {
+	keys := newTestKeys(t)
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-release
+		}
+		_, _ = w.Write(keys.jwks(t))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ks := NewKeySetFromURL(server.URL, WithKeySetMinRefreshInterval(0))
+	_, err := ks.Key(context.Background(), "rsa")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = ks.Key(ctx, "unknown")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = ks.Key(context.Background(), "rsa")
+	assert.NoError(t, err)
+}
@@ -3,30 +3,46 @@ package jwt
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
-	"strings"
+	"time"
 
 	gojwt "github.com/golang-jwt/jwt/v5"
 )
 
 // Middleware is a middleware that extracts and validates a JWT from the
-// Authorization header.
+// Authorization header, or from the places set WithExtractors.
 type Middleware struct {
-	publicKey interface{}
-	newClaims func() gojwt.Claims
-	audience  string
-	issuer    string
+	publicKey  interface{}
+	newClaims  func() gojwt.Claims
+	audience   string
+	issuer     string
+	required   bool
+	discovery  *discovery
+	extractors []Extractor
+
+	audiences        []string
+	issuers          []string
+	validMethods     []string
+	leeway           time.Duration
+	requiredClaims   []string
+	timeFunc         func() time.Time
+	revocation       Revocation
+	tenants          map[string]*Tenant
+	introspector     *Introspector
+	certificateBound bool
+	decryptionKey    interface{}
 }
 
 // Option is an option for NewMiddleware.
 type Option func(*Middleware)
 
 // WithKey sets the key to use for JWT validation. This can be the key itself, a
-// keyfunc (see https://godoc.org/github.com/golang-jwt/jwt#Keyfunc), or a
-// no-arg function that returns the key.
+// keyfunc (see https://godoc.org/github.com/golang-jwt/jwt#Keyfunc), a no-arg
+// function that returns the key, or a KeySource such as a KeySet.
 //
-// If the key is a keyfunc or a no-arg function, it will be called for each
-// request.
+// If the key is a keyfunc, a no-arg function or a KeySource, it will be called
+// for each request.
 func WithKey(publicKey interface{}) Option {
 	return func(m *Middleware) {
 		m.publicKey = publicKey
@@ -60,14 +76,77 @@ func WithIssuer(issuer string) Option {
 	}
 }
 
+// WithAudiences adds accepted audiences. The token must contain an audience
+// claim that matches at least one of the audiences set WithAudience or
+// WithAudiences.
+func WithAudiences(audiences ...string) Option {
+	return func(m *Middleware) {
+		m.audiences = append(m.audiences, audiences...)
+	}
+}
+
+// WithIssuers adds accepted issuers. The token must contain an issuer claim
+// that matches one of the issuers set WithIssuer or WithIssuers.
+func WithIssuers(issuers ...string) Option {
+	return func(m *Middleware) {
+		m.issuers = append(m.issuers, issuers...)
+	}
+}
+
+// WithValidMethods sets the signing algorithms, such as "RS256", that tokens
+// may use. Tokens signed with any other algorithm are rejected. By default,
+// any algorithm the key supports is accepted.
+func WithValidMethods(methods ...string) Option {
+	return func(m *Middleware) {
+		m.validMethods = methods
+	}
+}
+
+// WithLeeway sets the leeway allowed when checking the exp, nbf and iat
+// claims, to account for clock skew between hosts.
+func WithLeeway(leeway time.Duration) Option {
+	return func(m *Middleware) {
+		m.leeway = leeway
+	}
+}
+
+// WithRequiredClaims sets claims, such as "exp" or "sub", that tokens must
+// contain.
+func WithRequiredClaims(claims ...string) Option {
+	return func(m *Middleware) {
+		m.requiredClaims = claims
+	}
+}
+
+// WithTimeFunc sets the function used to get the current time when checking
+// the exp, nbf and iat claims. This is useful for deterministic tests. The
+// default is time.Now.
+func WithTimeFunc(timeFunc func() time.Time) Option {
+	return func(m *Middleware) {
+		m.timeFunc = timeFunc
+	}
+}
+
+// WithRequired sets whether a valid token is required. If required, requests
+// without a valid token are rejected with 401 Unauthorized and a
+// WWW-Authenticate header as described in RFC 6750, instead of being passed
+// through without claims.
+func WithRequired(required bool) Option {
+	return func(m *Middleware) {
+		m.required = required
+	}
+}
+
 // NewMiddleware returns a middleware. The middleware extracts and validates a
-// JWT from the Authorization header, and stores the parsed claims in the
-// request context under the key ContextKeyClaims.
+// JWT from the Authorization header (see WithExtractors), and stores the
+// parsed claims in the request context under the key ContextKeyClaims. The raw
+// token and its header are stored under ContextKeyToken and ContextKeyHeader.
 func NewMiddleware(opts ...Option) *Middleware {
 	m := &Middleware{
 		newClaims: func() gojwt.Claims {
 			return &gojwt.RegisteredClaims{}
 		},
+		extractors: []Extractor{FromHeader("Authorization", "Bearer")},
 	}
 	for _, opt := range opts {
 		opt(m)
@@ -75,57 +154,310 @@ func NewMiddleware(opts ...Option) *Middleware {
 	return m
 }
 
+// NewMiddlewareWithClaims is like NewMiddleware with WithNewClaims, but the
+// type of the claims is checked at compile time. Use ClaimsFrom with the same
+// type to retrieve the claims in a handler.
+func NewMiddlewareWithClaims[T gojwt.Claims](newClaims func() T, opts ...Option) *Middleware {
+	opts = append([]Option{WithNewClaims(func() gojwt.Claims { return newClaims() })}, opts...)
+	return NewMiddleware(opts...)
+}
+
 // Wrap the handler with middleware that extracts and validates a JWT from the
 // Authorization header, and stores the parsed claims in the request context
-// under the key ContextKeyClaims.
+// under the key ContextKeyClaims. If the middleware was created WithRequired,
+// requests without a valid token are rejected.
 func (m *Middleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if token, err := m.getToken(r); err == nil {
-			if claims, err := m.parseClaims(token); err == nil {
-				ctx := context.WithValue(r.Context(), ContextKeyClaims, claims)
-				r = r.WithContext(ctx)
+		tok, tenant, err := m.authenticate(r)
+		if err != nil {
+			if m.required {
+				unauthorized(w, err)
+				return
+			}
+		} else {
+			ctx := context.WithValue(r.Context(), ContextKeyClaims, tok.Claims)
+			ctx = context.WithValue(ctx, ContextKeyToken, tok.Raw)
+			ctx = context.WithValue(ctx, ContextKeyHeader, tok.Header)
+			if tenant != nil {
+				ctx = context.WithValue(ctx, ContextKeyTenant, tenant.ID)
 			}
+			r = r.WithContext(ctx)
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
-// getToken extracts the token from the Authorization header.
-func (m *Middleware) getToken(r *http.Request) (string, error) {
-	auth := r.Header.Get("Authorization")
-	if strings.HasPrefix(auth, "Bearer ") {
-		return auth[7:], nil
+// authenticate extracts the token from the request and parses it.
+func (m *Middleware) authenticate(r *http.Request) (*gojwt.Token, *Tenant, error) {
+	token, err := m.getToken(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	tok, tenant, err := m.parseToken(r.Context(), token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.certificateBound {
+		if err := checkCertificateBinding(r, tok); err != nil {
+			return nil, nil, err
+		}
 	}
-	return "", errors.New("no token")
+	return tok, tenant, nil
+}
+
+// getToken extracts the token from the request using the extractors.
+func (m *Middleware) getToken(r *http.Request) (string, error) {
+	return extract(r, m.extractors)
+}
+
+// Validate parses and validates a token exactly as the middleware does for a
+// request, and returns the parsed token. If the token is invalid but could be
+// parsed, it is returned along with the error. This is useful for validating tokens
+// received by other means than HTTP, and for tools that must agree with the
+// server about which tokens are valid. Checks that depend on the request, such
+// as WithCertificateBinding, are not made.
+func (m *Middleware) Validate(token string) (*gojwt.Token, error) {
+	tok, _, err := m.parseToken(context.Background(), token)
+	return tok, err
 }
 
 // parseClaims parses the claims from the token. using the key.
 func (m *Middleware) parseClaims(token string) (gojwt.Claims, error) {
+	tok, _, err := m.parseToken(context.Background(), token)
+	if err != nil {
+		return nil, err
+	}
+	return tok.Claims, nil
+}
+
+// verification is what a token is validated against.
+type verification struct {
+	audiences    []string
+	issuers      []string
+	validMethods []string
+	keyFunc      gojwt.Keyfunc
+	newClaims    func() gojwt.Claims
+	tenant       *Tenant
+}
+
+// verificationFor returns what the token should be validated against.
+func (m *Middleware) verificationFor(ctx context.Context, token string) (*verification, error) {
+	v := &verification{
+		audiences:    m.acceptedAudiences(),
+		issuers:      m.acceptedIssuers(),
+		validMethods: m.validMethods,
+		keyFunc:      m.keyFunc,
+		newClaims:    m.newClaims,
+	}
+	if len(m.tenants) > 0 {
+		tenant, err := m.tenantFor(token)
+		if err != nil {
+			return nil, err
+		}
+		v.tenant = tenant
+		v.issuers = []string{tenant.Issuer}
+		if len(tenant.Audiences) > 0 {
+			v.audiences = tenant.Audiences
+		}
+		v.keyFunc = func(token *gojwt.Token) (interface{}, error) {
+			return resolveKey(tenant.Key, token)
+		}
+		if tenant.NewClaims != nil {
+			v.newClaims = tenant.NewClaims
+		}
+	}
+	if m.discovery != nil {
+		md, keySet, err := m.discovery.get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		if len(v.issuers) == 0 {
+			v.issuers = []string{md.Issuer}
+		} else if !containsAny(v.issuers, []string{md.Issuer}) {
+			return nil, fmt.Errorf("%w: discovered issuer %q is not accepted", ErrUnavailable, md.Issuer)
+		}
+		if len(md.IDTokenSigningAlgValuesSupported) > 0 {
+			v.validMethods = intersect(v.validMethods, md.IDTokenSigningAlgValuesSupported)
+		}
+		if m.publicKey == nil {
+			v.keyFunc = keySet.Keyfunc
+		}
+	}
+	return v, nil
+}
+
+// parseToken parses and validates the token. If the middleware has tenants,
+// the tenant that issued the token is also returned.
+func (m *Middleware) parseToken(ctx context.Context, token string) (*gojwt.Token, *Tenant, error) {
+	if m.decryptionKey != nil && isJWE(token) {
+		inner, err := DecryptToken(token, m.decryptionKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		token = inner
+	}
+	if m.introspector != nil && !isJWT(token) {
+		tok, err := m.introspect(ctx, token)
+		return tok, nil, err
+	}
+	v, err := m.verificationFor(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims := v.newClaims()
+	tok, err := gojwt.ParseWithClaims(token, claims, v.keyFunc, m.parserOptions(v)...)
+	if errors.Is(err, gojwt.ErrTokenInvalidClaims) {
+		// The signature is valid, so report every invalid claim.
+		if cerr := m.validateClaims(claims, v.audiences, v.issuers); cerr != nil {
+			err = errors.Join(err, cerr)
+		}
+	}
+	if err != nil {
+		return tok, nil, err
+	}
+	if err := m.checkClaims(ctx, claims, v); err != nil {
+		tok.Valid = false
+		return tok, nil, err
+	}
+
+	return tok, v.tenant, nil
+}
+
+// parserOptions returns the parser options for the verification.
+func (m *Middleware) parserOptions(v *verification) []gojwt.ParserOption {
 	opts := []gojwt.ParserOption{}
-	if m.audience != "" {
-		opts = append(opts, gojwt.WithAudience(m.audience))
+	if len(v.audiences) == 1 {
+		opts = append(opts, gojwt.WithAudience(v.audiences[0]))
+	}
+	if len(v.issuers) == 1 {
+		opts = append(opts, gojwt.WithIssuer(v.issuers[0]))
 	}
-	if m.issuer != "" {
-		opts = append(opts, gojwt.WithIssuer(m.issuer))
+	if v.validMethods != nil {
+		opts = append(opts, gojwt.WithValidMethods(v.validMethods))
 	}
+	if m.leeway != 0 {
+		opts = append(opts, gojwt.WithLeeway(m.leeway))
+	}
+	if m.timeFunc != nil {
+		opts = append(opts, gojwt.WithTimeFunc(m.timeFunc))
+	}
+	return opts
+}
 
-	claims := m.newClaims()
-	if _, err := gojwt.ParseWithClaims(token, claims, m.keyFunc, opts...); err != nil {
-		return nil, err
+// checkClaims performs the checks made after the parser has validated the
+// token: claims validation the parser cannot do, and revocation.
+func (m *Middleware) checkClaims(ctx context.Context, claims gojwt.Claims, v *verification) error {
+	if err := m.validateClaims(claims, v.audiences, v.issuers); err != nil {
+		return err
+	}
+	if m.revocation != nil {
+		revoked, err := m.revocation.Revoked(ctx, claims)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+// validateClaims performs the checks that the parser cannot: more than one
+// accepted audience or issuer, and arbitrary required claims. All failed
+// checks are reported.
+func (m *Middleware) validateClaims(claims gojwt.Claims, audiences, issuers []string) error {
+	var errs []error
+	if len(audiences) > 1 {
+		aud, err := claims.GetAudience()
+		if err != nil {
+			return err
+		}
+		if len(aud) == 0 {
+			errs = append(errs, fmt.Errorf("%w: %w: aud claim is required", gojwt.ErrTokenInvalidClaims, gojwt.ErrTokenRequiredClaimMissing))
+		} else if !containsAny(aud, audiences) {
+			errs = append(errs, fmt.Errorf("%w: %w", gojwt.ErrTokenInvalidClaims, gojwt.ErrTokenInvalidAudience))
+		}
+	}
+	if len(issuers) > 1 {
+		iss, err := claims.GetIssuer()
+		if err != nil {
+			return err
+		}
+		if iss == "" {
+			errs = append(errs, fmt.Errorf("%w: %w: iss claim is required", gojwt.ErrTokenInvalidClaims, gojwt.ErrTokenRequiredClaimMissing))
+		} else if !containsAny(issuers, []string{iss}) {
+			errs = append(errs, fmt.Errorf("%w: %w", gojwt.ErrTokenInvalidClaims, gojwt.ErrTokenInvalidIssuer))
+		}
+	}
+	if len(m.requiredClaims) > 0 {
+		present, err := claimsMap(claims)
+		if err != nil {
+			return err
+		}
+		for _, name := range m.requiredClaims {
+			if _, ok := present[name]; !ok {
+				errs = append(errs, fmt.Errorf("%w: %w: %s claim is required", gojwt.ErrTokenInvalidClaims, gojwt.ErrTokenRequiredClaimMissing, name))
+			}
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	return claims, nil
+// acceptedAudiences returns the audiences set WithAudience and WithAudiences.
+func (m *Middleware) acceptedAudiences() []string {
+	if m.audience == "" {
+		return m.audiences
+	}
+	return append([]string{m.audience}, m.audiences...)
+}
+
+// acceptedIssuers returns the issuers set WithIssuer and WithIssuers.
+func (m *Middleware) acceptedIssuers() []string {
+	if m.issuer == "" {
+		return m.issuers
+	}
+	return append([]string{m.issuer}, m.issuers...)
+}
+
+// intersect returns the elements of b that are also in a. If a is nil, it
+// returns b.
+func intersect(a, b []string) []string {
+	if a == nil {
+		return b
+	}
+	result := []string{}
+	for _, s := range b {
+		if containsAny(a, []string{s}) {
+			result = append(result, s)
+		}
+	}
+	return result
 }
 
 // keyFunc returns the key to use for JWT validation.
 func (m *Middleware) keyFunc(token *gojwt.Token) (interface{}, error) {
-	if keyfunc, ok := m.publicKey.(func(*gojwt.Token) (interface{}, error)); ok {
+	return resolveKey(m.publicKey, token)
+}
+
+// resolveKey returns the key to use to validate the token, given a key in any
+// of the forms accepted by WithKey.
+func resolveKey(key interface{}, token *gojwt.Token) (interface{}, error) {
+	if source, ok := key.(KeySource); ok {
+		return source.Keyfunc(token)
+	}
+
+	if keyfunc, ok := key.(func(*gojwt.Token) (interface{}, error)); ok {
+		return keyfunc(token)
+	}
+
+	if keyfunc, ok := key.(gojwt.Keyfunc); ok {
 		return keyfunc(token)
 	}
 
-	if keyfunc, ok := m.publicKey.(func() (interface{}, error)); ok {
+	if keyfunc, ok := key.(func() (interface{}, error)); ok {
 		return keyfunc()
 	}
 
-	return m.publicKey, nil
+	return key, nil
 }
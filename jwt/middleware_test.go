@@ -1,10 +1,14 @@
 package jwt
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	gojwt "github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
@@ -325,3 +329,275 @@ func Test_That_Wrap_Returns_A_Handler_That_Sets_The_Claims_In_The_Request_Contex
 		},
 	})
 }
+
+func Test_That_Wrap_Rejects_A_Request_Without_A_Token_When_Required(t *testing.T) {
+	middleware := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithRequired(true),
+	)
+
+	handler := middleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, &http.Request{})
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"error\":\"unauthorized\"}\n", rec.Body.String())
+}
+
+func Test_That_Wrap_Rejects_A_Request_With_An_Invalid_Token_When_Required(t *testing.T) {
+	expired, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{
+		ExpiresAt: gojwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	wrongAudience, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{
+		Audience: []string{"other"},
+	}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	wrongKey, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{
+		Audience: []string{"test"},
+	}).SignedString([]byte("other"))
+	assert.NoError(t, err)
+
+	for token, description := range map[string]string{
+		"test":        "token is malformed",
+		expired:       "token is expired",
+		wrongAudience: "token has invalid audience",
+		wrongKey:      "token signature is invalid",
+	} {
+		middleware := NewMiddleware(
+			WithKey([]byte("secret")),
+			WithAudience("test"),
+			WithRequired(true),
+		)
+
+		handler := middleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("handler should not be called")
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, &http.Request{
+			Header: http.Header{
+				"Authorization": []string{"Bearer " + token},
+			},
+		})
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, `Bearer error="invalid_token", error_description="`+description+`"`, rec.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, "{\"error\":\"unauthorized\"}\n", rec.Body.String())
+	}
+}
+
+// failingRevocation is a Revocation whose store is unavailable.
+type failingRevocation struct{}
+
+// Revoked fails.
+func (failingRevocation) Revoked(context.Context, gojwt.Claims) (bool, error) {
+	return false, errors.New("revocation store is unavailable")
+}
+
+func Test_That_Wrap_Fails_With_A_Server_Error_When_Validation_Is_Unavailable(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	signed, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{Subject: "test"}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		opt   Option
+		token string
+	}{
+		"discovery":     {WithOIDCDiscovery(closed.URL), signed},
+		"key set":       {WithKey(NewKeySetFromURL(down.URL)), signed},
+		"revocation":    {WithRevocation(failingRevocation{}), signed},
+		"introspection": {WithIntrospection(NewIntrospector(down.URL)), "opaque"},
+	} {
+		middleware := NewMiddleware(
+			WithKey([]byte("secret")),
+			tc.opt,
+			WithRequired(true),
+		)
+
+		handler := middleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("handler should not be called", name)
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code, name)
+		assert.Empty(t, rec.Header().Get("WWW-Authenticate"), name)
+		assert.Equal(t, "{\"error\":\"internal error\"}\n", rec.Body.String(), name)
+	}
+}
+
+func Test_That_Wrap_Passes_A_Request_With_A_Valid_Token_When_Required(t *testing.T) {
+	middleware := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithRequired(true),
+	)
+
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{Subject: "test"}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	var called bool
+	handler := middleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		claims := r.Context().Value(ContextKeyClaims).(*gojwt.RegisteredClaims)
+		assert.Equal(t, "test", claims.Subject)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, &http.Request{
+		Header: http.Header{
+			"Authorization": []string{"Bearer " + token},
+		},
+	})
+
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func Test_That_WithValidMethods_Rejects_Tokens_Signed_With_Other_Algorithms(t *testing.T) {
+	middleware := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithValidMethods("HS512"),
+	)
+
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{Subject: "test"}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(token)
+	assert.ErrorIs(t, err, gojwt.ErrTokenSignatureInvalid)
+
+	token, err = gojwt.NewWithClaims(gojwt.SigningMethodHS512, &gojwt.RegisteredClaims{Subject: "test"}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(token)
+	assert.NoError(t, err)
+}
+
+func Test_That_WithLeeway_And_WithTimeFunc_Control_Expiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{
+		ExpiresAt: gojwt.NewNumericDate(now.Add(-30 * time.Second)),
+	}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	middleware := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithTimeFunc(func() time.Time { return now }),
+	)
+	_, err = middleware.parseClaims(token)
+	assert.ErrorIs(t, err, gojwt.ErrTokenExpired)
+
+	middleware = NewMiddleware(
+		WithKey([]byte("secret")),
+		WithTimeFunc(func() time.Time { return now }),
+		WithLeeway(time.Minute),
+	)
+	_, err = middleware.parseClaims(token)
+	assert.NoError(t, err)
+}
+
+func Test_That_WithRequiredClaims_Rejects_Tokens_Missing_A_Claim(t *testing.T) {
+	middleware := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithRequiredClaims("sub", "exp"),
+	)
+
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{Subject: "test"}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(token)
+	assert.ErrorIs(t, err, gojwt.ErrTokenRequiredClaimMissing)
+	assert.Equal(t, "token has invalid claims: token is missing required claim: exp claim is required", err.Error())
+
+	token, err = gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{
+		Subject:   "test",
+		ExpiresAt: gojwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(token)
+	assert.NoError(t, err)
+}
+
+func Test_That_WithAudiences_And_WithIssuers_Accept_Any_Of_Several_Values(t *testing.T) {
+	middleware := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithAudience("a"),
+		WithAudiences("b", "c"),
+		WithIssuers("x", "y"),
+	)
+
+	for _, tc := range []struct {
+		claims *gojwt.RegisteredClaims
+		err    error
+	}{
+		{&gojwt.RegisteredClaims{Audience: []string{"c"}, Issuer: "x"}, nil},
+		{&gojwt.RegisteredClaims{Audience: []string{"z", "a"}, Issuer: "y"}, nil},
+		{&gojwt.RegisteredClaims{Audience: []string{"z"}, Issuer: "x"}, gojwt.ErrTokenInvalidAudience},
+		{&gojwt.RegisteredClaims{Issuer: "x"}, gojwt.ErrTokenRequiredClaimMissing},
+		{&gojwt.RegisteredClaims{Audience: []string{"a"}, Issuer: "z"}, gojwt.ErrTokenInvalidIssuer},
+		{&gojwt.RegisteredClaims{Audience: []string{"a"}}, gojwt.ErrTokenRequiredClaimMissing},
+	} {
+		token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, tc.claims).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		_, err = middleware.parseClaims(token)
+		if tc.err == nil {
+			assert.NoError(t, err)
+		} else {
+			assert.ErrorIs(t, err, tc.err)
+		}
+	}
+}
+
+func Test_That_Validate_Returns_The_Parsed_Token(t *testing.T) {
+	secret := []byte("secret")
+	signed, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{Subject: "jdoe", Audience: gojwt.ClaimStrings{"api"}}).SignedString(secret)
+	assert.NoError(t, err)
+
+	tok, err := NewMiddleware(WithKey(secret), WithAudience("api")).Validate(signed)
+	assert.NoError(t, err)
+	assert.True(t, tok.Valid)
+	assert.Equal(t, signed, tok.Raw)
+	sub, err := tok.Claims.GetSubject()
+	assert.NoError(t, err)
+	assert.Equal(t, "jdoe", sub)
+
+	_, err = NewMiddleware(WithKey(secret), WithAudience("other")).Validate(signed)
+	assert.ErrorIs(t, err, gojwt.ErrTokenInvalidAudience)
+}
+
+func Test_That_Validate_Reports_Every_Invalid_Claim(t *testing.T) {
+	secret := []byte("secret")
+	signed, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{
+		Issuer:    "https://other.example.com",
+		ExpiresAt: gojwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	}).SignedString(secret)
+	assert.NoError(t, err)
+
+	tok, err := NewMiddleware(WithKey(secret), WithIssuers("a", "b"), WithRequiredClaims("sub")).Validate(signed)
+	assert.NotNil(t, tok)
+	assert.False(t, tok.Valid)
+	assert.ErrorIs(t, err, gojwt.ErrTokenExpired)
+	assert.ErrorIs(t, err, gojwt.ErrTokenInvalidIssuer)
+	assert.ErrorIs(t, err, gojwt.ErrTokenRequiredClaimMissing)
+}
+
+func Test_That_WithKey_Accepts_A_gojwt_Keyfunc(t *testing.T) {
+	secret := []byte("secret")
+	signed, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{Subject: "jdoe"}).SignedString(secret)
+	assert.NoError(t, err)
+
+	var keyfunc gojwt.Keyfunc = func(*gojwt.Token) (interface{}, error) { return secret, nil }
+	_, err = NewMiddleware(WithKey(keyfunc)).Validate(signed)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,282 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/smxlong/kit/boolean"
+	"github.com/smxlong/kit/rest"
+)
+
+var (
+	// ErrNoClientCertificate is returned when a request has no verified client
+	// certificate.
+	ErrNoClientCertificate = errors.New("no verified client certificate")
+	// ErrCertificateNotAllowed is returned when a client certificate does not
+	// satisfy the policy of a CertificateMiddleware.
+	ErrCertificateNotAllowed = errors.New("client certificate is not allowed")
+	// ErrCertificateMismatch is returned when a certificate-bound token is not
+	// bound to the request's client certificate.
+	ErrCertificateMismatch = errors.New("token is not bound to the client certificate")
+)
+
+// ClientCertificate is the identity in a verified TLS client certificate.
+type ClientCertificate struct {
+	// Certificate is the client certificate.
+	Certificate *x509.Certificate
+	// Subject is the certificate's subject distinguished name.
+	Subject string
+	// CommonName is the common name of the certificate's subject.
+	CommonName string
+	// DNSNames are the DNS name SANs of the certificate.
+	DNSNames []string
+	// URIs are the URI SANs of the certificate.
+	URIs []string
+	// EmailAddresses are the email address SANs of the certificate.
+	EmailAddresses []string
+	// SPIFFEID is the certificate's SPIFFE ID: its URI SAN, if it has exactly
+	// one and it has the spiffe scheme.
+	SPIFFEID string
+	// Thumbprint is the base64url-encoded SHA-256 hash of the DER-encoded
+	// certificate, as used in the x5t#S256 confirmation claim (RFC 8705).
+	Thumbprint string
+}
+
+// NewClientCertificate returns the identity in the given certificate.
+func NewClientCertificate(cert *x509.Certificate) *ClientCertificate {
+	c := &ClientCertificate{
+		Certificate:    cert,
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Thumbprint:     certificateThumbprint(cert),
+	}
+	for _, uri := range cert.URIs {
+		c.URIs = append(c.URIs, uri.String())
+	}
+	if len(cert.URIs) == 1 && cert.URIs[0].Scheme == "spiffe" {
+		c.SPIFFEID = c.URIs[0]
+	}
+	return c
+}
+
+// claims returns the principal for the certificate, in the shape of JWT claims
+// so that Require and the predicates can be used with it. The sub claim is the
+// SPIFFE ID, or the common name if there is none, and the cnf claim holds the
+// certificate's thumbprint.
+func (c *ClientCertificate) claims() gojwt.MapClaims {
+	sub := c.SPIFFEID
+	if sub == "" {
+		sub = c.CommonName
+	}
+	claims := gojwt.MapClaims{
+		"cnf": map[string]interface{}{"x5t#S256": c.Thumbprint},
+	}
+	if sub != "" {
+		claims["sub"] = sub
+	}
+	return claims
+}
+
+// AllowSPIFFEID returns a policy that allows certificates with any of the
+// given SPIFFE IDs.
+func AllowSPIFFEID(ids ...string) boolean.Predicate[*ClientCertificate] {
+	return func(c *ClientCertificate) bool {
+		return c.SPIFFEID != "" && containsAny([]string{c.SPIFFEID}, ids)
+	}
+}
+
+// AllowTrustDomain returns a policy that allows certificates with a SPIFFE ID
+// in any of the given trust domains, such as "example.org".
+func AllowTrustDomain(domains ...string) boolean.Predicate[*ClientCertificate] {
+	return func(c *ClientCertificate) bool {
+		if c.SPIFFEID == "" {
+			return false
+		}
+		domain := c.Certificate.URIs[0].Host
+		for _, d := range domains {
+			if strings.EqualFold(domain, d) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AllowDNSName returns a policy that allows certificates with any of the given
+// DNS name SANs. Names are compared without regard to case.
+func AllowDNSName(names ...string) boolean.Predicate[*ClientCertificate] {
+	return func(c *ClientCertificate) bool {
+		for _, have := range c.DNSNames {
+			for _, want := range names {
+				if strings.EqualFold(have, want) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// AllowEmail returns a policy that allows certificates with any of the given
+// email address SANs.
+func AllowEmail(addresses ...string) boolean.Predicate[*ClientCertificate] {
+	return func(c *ClientCertificate) bool {
+		return containsAny(c.EmailAddresses, addresses)
+	}
+}
+
+// AllowCommonName returns a policy that allows certificates whose subject has
+// any of the given common names.
+func AllowCommonName(names ...string) boolean.Predicate[*ClientCertificate] {
+	return func(c *ClientCertificate) bool {
+		return c.CommonName != "" && containsAny([]string{c.CommonName}, names)
+	}
+}
+
+// CertificateMiddleware authenticates requests with the client certificate
+// verified during the TLS handshake, so the server's tls.Config must have a
+// ClientAuth of tls.VerifyClientCertIfGiven or tls.RequireAndVerifyClientCert.
+//
+// It is a sibling of Middleware: the certificate's principal is stored in the
+// request context as gojwt.MapClaims, so that Require and ClaimsFrom work the
+// same as for JWTs. The ClientCertificate itself is available from
+// ClientCertificateFrom.
+type CertificateMiddleware struct {
+	policy   boolean.Predicate[*ClientCertificate]
+	required bool
+}
+
+// CertificateOption is an option for a CertificateMiddleware.
+type CertificateOption func(*CertificateMiddleware)
+
+// WithCertificatePolicy sets the policy client certificates must satisfy,
+// typically built from AllowSPIFFEID, AllowTrustDomain, AllowDNSName,
+// AllowEmail and AllowCommonName. The default allows any verified certificate.
+func WithCertificatePolicy(policy boolean.Predicate[*ClientCertificate]) CertificateOption {
+	return func(m *CertificateMiddleware) {
+		m.policy = policy
+	}
+}
+
+// WithCertificateRequired sets whether an allowed client certificate is
+// required. If required, requests without a verified certificate are rejected
+// with 401 Unauthorized, and requests whose certificate is not allowed with 403
+// Forbidden. Otherwise they are passed on without a principal.
+func WithCertificateRequired(required bool) CertificateOption {
+	return func(m *CertificateMiddleware) {
+		m.required = required
+	}
+}
+
+// NewCertificateMiddleware returns a CertificateMiddleware.
+func NewCertificateMiddleware(opts ...CertificateOption) *CertificateMiddleware {
+	m := &CertificateMiddleware{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Wrap wraps an http.Handler with the client certificate middleware.
+func (m *CertificateMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cert, err := m.authenticate(r)
+		if err != nil {
+			if m.required {
+				if errors.Is(err, ErrCertificateNotAllowed) {
+					rest.WriteError(w, rest.ErrForbidden.WithCause(err))
+				} else {
+					rest.WriteError(w, rest.ErrUnauthorized.WithCause(err))
+				}
+				return
+			}
+		} else {
+			ctx := context.WithValue(r.Context(), ContextKeyClaims, cert.claims())
+			ctx = context.WithValue(ctx, ContextKeyClientCertificate, cert)
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the identity in the request's client certificate.
+func (m *CertificateMiddleware) authenticate(r *http.Request) (*ClientCertificate, error) {
+	x509Cert := verifiedClientCertificate(r)
+	if x509Cert == nil {
+		return nil, ErrNoClientCertificate
+	}
+	cert := NewClientCertificate(x509Cert)
+	if m.policy != nil && !m.policy(cert) {
+		return nil, ErrCertificateNotAllowed
+	}
+	return cert, nil
+}
+
+// ClientCertificateFrom returns the client certificate identity stored in the
+// context by CertificateMiddleware.
+func ClientCertificateFrom(ctx context.Context) (*ClientCertificate, bool) {
+	cert, ok := ctx.Value(ContextKeyClientCertificate).(*ClientCertificate)
+	return cert, ok
+}
+
+// WithCertificateBinding makes the middleware require certificate-bound
+// tokens, as described in RFC 8705: the token's cnf claim must have an
+// x5t#S256 member equal to the thumbprint of the request's verified TLS client
+// certificate. This prevents a stolen token from being used by a client that
+// does not hold the certificate's private key.
+func WithCertificateBinding() Option {
+	return func(m *Middleware) {
+		m.certificateBound = true
+	}
+}
+
+// checkCertificateBinding checks that the token is bound to the request's
+// client certificate. The cnf claim is read from the raw token, since the
+// token's claims type may not have a field for it.
+func checkCertificateBinding(r *http.Request, tok *gojwt.Token) error {
+	cert := verifiedClientCertificate(r)
+	if cert == nil {
+		return fmt.Errorf("%w: %w", ErrCertificateMismatch, ErrNoClientCertificate)
+	}
+	claims, err := claimsMap(tok.Claims)
+	if err != nil {
+		return err
+	}
+	if isJWT(tok.Raw) {
+		if _, _, err := gojwt.NewParser().ParseUnverified(tok.Raw, gojwt.MapClaims(claims)); err != nil {
+			return err
+		}
+	}
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	thumbprint, _ := cnf["x5t#S256"].(string)
+	if thumbprint == "" || thumbprint != certificateThumbprint(cert) {
+		return ErrCertificateMismatch
+	}
+	return nil
+}
+
+// verifiedClientCertificate returns the request's client certificate, if it
+// was verified during the TLS handshake.
+func verifiedClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// certificateThumbprint returns the base64url-encoded SHA-256 hash of the
+// DER-encoded certificate.
+func certificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
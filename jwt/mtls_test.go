@@ -0,0 +1,177 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smxlong/kit/boolean"
+)
+
+// newTestCertificate returns a self-signed client certificate from the given
+// template.
+func newTestCertificate(t *testing.T, template *x509.Certificate) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template.SerialNumber = big.NewInt(1)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert
+}
+
+// newTLSRequest returns a request whose connection verified the given client
+// certificate. If cert is nil, the connection has no client certificate.
+func newTLSRequest(cert *x509.Certificate) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	r.TLS = &tls.ConnectionState{}
+	if cert != nil {
+		r.TLS.PeerCertificates = []*x509.Certificate{cert}
+		r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return r
+}
+
+func Test_That_NewClientCertificate_Extracts_The_Identity(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/prod/sa/billing")
+	cert := newTestCertificate(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "billing", Organization: []string{"Example"}},
+		DNSNames:       []string{"billing.example.org"},
+		URIs:           []*url.URL{spiffe},
+		EmailAddresses: []string{"billing@example.org"},
+	})
+	sum := sha256.Sum256(cert.Raw)
+
+	c := NewClientCertificate(cert)
+	assert.Equal(t, "CN=billing,O=Example", c.Subject)
+	assert.Equal(t, "billing", c.CommonName)
+	assert.Equal(t, []string{"billing.example.org"}, c.DNSNames)
+	assert.Equal(t, []string{"spiffe://example.org/ns/prod/sa/billing"}, c.URIs)
+	assert.Equal(t, []string{"billing@example.org"}, c.EmailAddresses)
+	assert.Equal(t, "spiffe://example.org/ns/prod/sa/billing", c.SPIFFEID)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:]), c.Thumbprint)
+}
+
+func Test_That_The_Certificate_Policies_Match_The_Identity(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/prod/sa/billing")
+	c := NewClientCertificate(newTestCertificate(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "billing"},
+		DNSNames:       []string{"billing.example.org"},
+		URIs:           []*url.URL{spiffe},
+		EmailAddresses: []string{"billing@example.org"},
+	}))
+
+	for name, tc := range map[string]struct {
+		policy boolean.Predicate[*ClientCertificate]
+		want   bool
+	}{
+		"SPIFFE ID":          {AllowSPIFFEID("spiffe://example.org/ns/prod/sa/billing"), true},
+		"other SPIFFE ID":    {AllowSPIFFEID("spiffe://example.org/ns/prod/sa/web"), false},
+		"trust domain":       {AllowTrustDomain("example.org"), true},
+		"other trust domain": {AllowTrustDomain("example.com"), false},
+		"DNS name":           {AllowDNSName("BILLING.example.org"), true},
+		"other DNS name":     {AllowDNSName("web.example.org"), false},
+		"email":              {AllowEmail("billing@example.org"), true},
+		"common name":        {AllowCommonName("web", "billing"), true},
+		"other common name":  {AllowCommonName("web"), false},
+	} {
+		assert.Equal(t, tc.want, tc.policy(c), name)
+	}
+}
+
+func Test_That_CertificateMiddleware_Stores_The_Principal_In_The_Context(t *testing.T) {
+	cert := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}})
+	m := NewCertificateMiddleware(
+		WithCertificatePolicy(AllowCommonName("billing")),
+		WithCertificateRequired(true),
+	)
+	var sub string
+	var c *ClientCertificate
+	h := m.Wrap(RequireClaim("sub", "billing")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFrom[gojwt.MapClaims](r.Context())
+		sub, _ = claims.GetSubject()
+		c, _ = ClientCertificateFrom(r.Context())
+	})))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newTLSRequest(cert))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "billing", sub)
+	assert.Equal(t, cert, c.Certificate)
+}
+
+func Test_That_CertificateMiddleware_Rejects_Missing_And_Disallowed_Certificates(t *testing.T) {
+	m := NewCertificateMiddleware(
+		WithCertificatePolicy(AllowCommonName("billing")),
+		WithCertificateRequired(true),
+	)
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newTLSRequest(nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	unverified := newTLSRequest(nil)
+	unverified.TLS.PeerCertificates = []*x509.Certificate{newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}})}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, unverified)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, newTLSRequest(newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "web"}})))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func Test_That_WithCertificateBinding_Requires_Tokens_Bound_To_The_Client_Certificate(t *testing.T) {
+	secret := []byte("secret")
+	cert := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}})
+	other := newTestCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}})
+	sign := func(claims gojwt.MapClaims) string {
+		s, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString(secret)
+		assert.NoError(t, err)
+		return s
+	}
+	bound := sign(gojwt.MapClaims{"sub": "billing", "cnf": map[string]interface{}{"x5t#S256": certificateThumbprint(cert)}})
+	unbound := sign(gojwt.MapClaims{"sub": "billing"})
+
+	m := NewMiddleware(WithKey(secret), WithRequired(true), WithCertificateBinding())
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tc := range []struct {
+		token  string
+		cert   *x509.Certificate
+		status int
+	}{
+		{bound, cert, http.StatusOK},
+		{bound, other, http.StatusUnauthorized},
+		{bound, nil, http.StatusUnauthorized},
+		{unbound, cert, http.StatusUnauthorized},
+	} {
+		r := newTLSRequest(tc.cert)
+		r.Header.Set("Authorization", "Bearer "+tc.token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, tc.status, w.Code)
+		if tc.status == http.StatusUnauthorized {
+			assert.Contains(t, w.Header().Get("WWW-Authenticate"), "token is not bound to the client certificate")
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderMetadata is the OpenID Connect provider metadata published at
+// /.well-known/openid-configuration, as described in OpenID Connect Discovery
+// 1.0. Only the fields used by this package are included.
+type ProviderMetadata struct {
+	// Issuer is the issuer identifier of the provider.
+	Issuer string `json:"issuer"`
+	// JWKSURI is the URL of the provider's JSON Web Key Set.
+	JWKSURI string `json:"jwks_uri"`
+	// AuthorizationEndpoint is the URL of the authorization endpoint.
+	AuthorizationEndpoint string `json:"authorization_endpoint,omitempty"`
+	// TokenEndpoint is the URL of the token endpoint.
+	TokenEndpoint string `json:"token_endpoint,omitempty"`
+	// UserinfoEndpoint is the URL of the userinfo endpoint.
+	UserinfoEndpoint string `json:"userinfo_endpoint,omitempty"`
+	// IDTokenSigningAlgValuesSupported are the signing algorithms the provider
+	// uses for ID tokens.
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+}
+
+// Discover fetches the OpenID Connect provider metadata for the given issuer
+// URL. It is an error if the issuer in the metadata does not match the issuer
+// URL.
+func Discover(ctx context.Context, client *http.Client, issuerURL string) (*ProviderMetadata, error) {
+	issuerURL = strings.TrimSuffix(issuerURL, "/")
+	data, err := fetch(ctx, client, issuerURL+"/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	md := &ProviderMetadata{}
+	if err := json.Unmarshal(data, md); err != nil {
+		return nil, fmt.Errorf("invalid provider metadata: %w", err)
+	}
+	if strings.TrimSuffix(md.Issuer, "/") != issuerURL {
+		return nil, fmt.Errorf("discovered issuer %q does not match %q", md.Issuer, issuerURL)
+	}
+	if md.JWKSURI == "" {
+		return nil, fmt.Errorf("provider metadata for %q has no jwks_uri", issuerURL)
+	}
+	return md, nil
+}
+
+// WithOIDCDiscovery configures the middleware from the OpenID Connect provider
+// metadata of the given issuer. The metadata is fetched on first use, and
+// refetched after a backoff if that fails. Once fetched,
+//
+//   - the discovered issuer must be one of the issuers set WithIssuer or
+//     WithIssuers, if any, and is otherwise required in tokens;
+//   - unless a key is set WithKey, tokens are validated against the keys at the
+//     discovered jwks_uri, using a KeySet with the given options;
+//   - token signing algorithms are restricted to those listed in the
+//     discovered id_token_signing_alg_values_supported (and those set
+//     WithValidMethods, if any).
+func WithOIDCDiscovery(issuerURL string, opts ...KeySetOption) Option {
+	return func(m *Middleware) {
+		m.discovery = &discovery{
+			issuerURL: issuerURL,
+			opts:      opts,
+			now:       time.Now,
+		}
+	}
+}
+
+// discovery lazily fetches and caches OpenID Connect provider metadata.
+// Concurrent fetches are coalesced into one. If discovery fails, the error is
+// returned until a backoff has elapsed, and discovery is then retried.
+type discovery struct {
+	issuerURL string
+	opts      []KeySetOption
+	now       func() time.Time
+
+	mu       sync.Mutex
+	metadata *ProviderMetadata
+	keySet   *KeySet
+	err      error
+	failures int
+	retryAt  time.Time
+	fetching chan struct{}
+}
+
+// Backoff between discovery attempts after a failure. The backoff doubles with
+// each consecutive failure, up to the maximum.
+const (
+	minDiscoveryBackoff = time.Second
+	maxDiscoveryBackoff = time.Minute
+)
+
+// get returns the provider metadata and key set, fetching them if necessary.
+// ctx bounds the wait for the fetch, but does not cancel it.
+func (d *discovery) get(ctx context.Context) (*ProviderMetadata, *KeySet, error) {
+	for {
+		d.mu.Lock()
+		if d.metadata != nil {
+			d.mu.Unlock()
+			return d.metadata, d.keySet, nil
+		}
+		if d.err != nil && d.now().Before(d.retryAt) {
+			err := d.err
+			d.mu.Unlock()
+			return nil, nil, err
+		}
+		if d.fetching == nil {
+			d.fetching = make(chan struct{})
+			go d.fetch(context.WithoutCancel(ctx), d.fetching)
+		}
+		fetching := d.fetching
+		d.mu.Unlock()
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+// fetch fetches the provider metadata, records the result and closes done.
+func (d *discovery) fetch(ctx context.Context, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	ks := newKeySet(d.opts...)
+	md, err := Discover(ctx, ks.client, d.issuerURL)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer close(done)
+	d.fetching = nil
+	if err != nil {
+		backoff := minDiscoveryBackoff << min(d.failures, 6)
+		d.failures++
+		d.err = err
+		d.retryAt = d.now().Add(min(backoff, maxDiscoveryBackoff))
+		return
+	}
+	ks.load = func(ctx context.Context) ([]byte, error) {
+		return fetch(ctx, ks.client, md.JWKSURI)
+	}
+	d.metadata, d.keySet, d.err = md, ks, nil
+}
@@ -0,0 +1,149 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestProvider starts a server that publishes OpenID Connect provider
+// metadata and the JWKS for the given keys. If issuer is empty, the server's
+// own URL is used.
+func newTestProvider(t *testing.T, keys *testKeys, issuer string, algs ...string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	if issuer == "" {
+		issuer = server.URL
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&ProviderMetadata{
+			Issuer:                           issuer,
+			JWKSURI:                          server.URL + "/jwks",
+			IDTokenSigningAlgValuesSupported: algs,
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(keys.jwks(t))
+	})
+	return server
+}
+
+// signTestTokenWithIssuer signs a token with the given issuer.
+func signTestTokenWithIssuer(t *testing.T, method gojwt.SigningMethod, kid string, key interface{}, issuer string) string {
+	t.Helper()
+	tok := gojwt.NewWithClaims(method, &gojwt.RegisteredClaims{Subject: "test", Issuer: issuer})
+	tok.Header["kid"] = kid
+	s, err := tok.SignedString(key)
+	assert.NoError(t, err)
+	return s
+}
+
+func Test_That_Discover_Returns_The_Provider_Metadata(t *testing.T) {
+	server := newTestProvider(t, newTestKeys(t), "", "RS256")
+
+	md, err := Discover(context.Background(), http.DefaultClient, server.URL+"/")
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL, md.Issuer)
+	assert.Equal(t, server.URL+"/jwks", md.JWKSURI)
+	assert.Equal(t, []string{"RS256"}, md.IDTokenSigningAlgValuesSupported)
+}
+
+func Test_That_Discover_Rejects_A_Mismatched_Issuer(t *testing.T) {
+	server := newTestProvider(t, newTestKeys(t), "https://evil.example.com")
+
+	_, err := Discover(context.Background(), http.DefaultClient, server.URL)
+	assert.Error(t, err)
+}
+
+func Test_That_WithOIDCDiscovery_Validates_Tokens_With_The_Discovered_Keys_And_Issuer(t *testing.T) {
+	keys := newTestKeys(t)
+	server := newTestProvider(t, keys, "")
+
+	middleware := NewMiddleware(WithOIDCDiscovery(server.URL))
+
+	claims, err := middleware.parseClaims(signTestTokenWithIssuer(t, gojwt.SigningMethodRS256, "rsa", keys.rsa, server.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, "test", claims.(*gojwt.RegisteredClaims).Subject)
+
+	_, err = middleware.parseClaims(signTestTokenWithIssuer(t, gojwt.SigningMethodRS256, "rsa", keys.rsa, "https://other.example.com"))
+	assert.ErrorIs(t, err, gojwt.ErrTokenInvalidIssuer)
+}
+
+func Test_That_WithOIDCDiscovery_Restricts_The_Signing_Algorithms(t *testing.T) {
+	keys := newTestKeys(t)
+	server := newTestProvider(t, keys, "", "ES256")
+
+	middleware := NewMiddleware(WithOIDCDiscovery(server.URL))
+
+	_, err := middleware.parseClaims(signTestTokenWithIssuer(t, gojwt.SigningMethodES256, "ec", keys.ecdsa, server.URL))
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(signTestTokenWithIssuer(t, gojwt.SigningMethodRS256, "rsa", keys.rsa, server.URL))
+	assert.ErrorIs(t, err, gojwt.ErrTokenSignatureInvalid)
+}
+
+func Test_That_WithOIDCDiscovery_Rejects_A_Discovered_Issuer_That_Does_Not_Match_WithIssuer(t *testing.T) {
+	keys := newTestKeys(t)
+	server := newTestProvider(t, keys, "")
+
+	middleware := NewMiddleware(
+		WithOIDCDiscovery(server.URL),
+		WithIssuer("https://other.example.com"),
+	)
+
+	_, err := middleware.parseClaims(signTestTokenWithIssuer(t, gojwt.SigningMethodRS256, "rsa", keys.rsa, "https://other.example.com"))
+	assert.Error(t, err)
+}
+
+func Test_That_WithOIDCDiscovery_Backs_Off_After_A_Failure(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	middleware := NewMiddleware(WithOIDCDiscovery(server.URL))
+	middleware.discovery.now = func() time.Time { return now }
+
+	_, _, err := middleware.discovery.get(context.Background())
+	assert.Error(t, err)
+	_, _, err = middleware.discovery.get(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	now = now.Add(minDiscoveryBackoff)
+	_, _, err = middleware.discovery.get(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	now = now.Add(minDiscoveryBackoff)
+	_, _, err = middleware.discovery.get(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func Test_That_WithOIDCDiscovery_Stops_Waiting_When_The_Request_Is_Canceled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	middleware := NewMiddleware(WithOIDCDiscovery(server.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := middleware.discovery.get(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
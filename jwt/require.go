@@ -0,0 +1,202 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/smxlong/kit/boolean"
+	"github.com/smxlong/kit/middleware"
+	"github.com/smxlong/kit/rest"
+)
+
+// Require returns middleware that only passes requests for which the policy
+// is true. The policy is typically built from HasScope, HasAnyRole and
+// HasClaim, combined with boolean.And, boolean.Or and boolean.Not.
+//
+// Requests without claims in their context are rejected with 401
+// Unauthorized, and requests for which the policy is false are rejected with
+// 403 Forbidden. Require must therefore be chained after a Middleware.
+func Require(policy boolean.Predicate[*http.Request]) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := ClaimsFrom[gojwt.Claims](r.Context()); !ok {
+				unauthorized(w, ErrNoToken)
+				return
+			}
+			if !policy(r) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+				rest.WriteError(w, rest.ErrForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope returns middleware that only passes requests whose claims
+// grant all of the given scopes. See HasScope.
+func RequireScope(scopes ...string) middleware.Middleware {
+	return Require(HasScope(scopes...))
+}
+
+// RequireAnyRole returns middleware that only passes requests whose claims
+// grant at least one of the given roles. See HasAnyRole.
+func RequireAnyRole(roles ...string) middleware.Middleware {
+	return Require(HasAnyRole(roles...))
+}
+
+// RequireClaim returns middleware that only passes requests whose claims
+// contain the given value at the given path. See HasClaim.
+func RequireClaim(path string, value interface{}) middleware.Middleware {
+	return Require(HasClaim(path, value))
+}
+
+// HasScope returns a predicate that is true if the request's claims grant all
+// of the given scopes. Scopes are read from the "scope" claim, or the "scp"
+// claim if there is no "scope" claim. Either may be a space-delimited string
+// or an array of strings.
+func HasScope(scopes ...string) boolean.Predicate[*http.Request] {
+	return func(r *http.Request) bool {
+		granted, ok := LookupClaim(r.Context(), "scope")
+		if !ok {
+			granted, _ = LookupClaim(r.Context(), "scp")
+		}
+		return containsAll(claimStrings(granted), scopes)
+	}
+}
+
+// HasAnyRole returns a predicate that is true if the request's claims grant
+// at least one of the given roles. Roles are read from the "roles" claim,
+// which may be a space-delimited string or an array of strings.
+func HasAnyRole(roles ...string) boolean.Predicate[*http.Request] {
+	return func(r *http.Request) bool {
+		granted, _ := LookupClaim(r.Context(), "roles")
+		return containsAny(claimStrings(granted), roles)
+	}
+}
+
+// HasClaim returns a predicate that is true if the request's claims contain
+// the given value at the given path. The path is a dot-separated list of
+// object keys, such as "tenant.id". If the claim is an array, the predicate is
+// true if any element equals the value.
+func HasClaim(path string, value interface{}) boolean.Predicate[*http.Request] {
+	want := normalize(value)
+	return func(r *http.Request) bool {
+		got, ok := LookupClaim(r.Context(), path)
+		if !ok {
+			return false
+		}
+		if reflect.DeepEqual(got, want) {
+			return true
+		}
+		if elems, ok := got.([]interface{}); ok {
+			for _, elem := range elems {
+				if reflect.DeepEqual(elem, want) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// LookupClaim returns the claim at the given path in the claims stored in the
+// context by Middleware. The path is a dot-separated list of object keys, such
+// as "tenant.id". Values are returned as decoded from JSON, so numbers are
+// float64, arrays are []interface{} and objects are map[string]interface{}.
+func LookupClaim(ctx context.Context, path string) (interface{}, bool) {
+	claims, ok := ClaimsFrom[gojwt.Claims](ctx)
+	if !ok {
+		return nil, false
+	}
+	m, err := claimsMap(claims)
+	if err != nil {
+		return nil, false
+	}
+	var value interface{} = m
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// claimsMap returns the claims as a map, as they would be decoded from JSON.
+func claimsMap(claims gojwt.Claims) (map[string]interface{}, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("encoding claims: %w", err)
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("claims are not a JSON object: %w", err)
+	}
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+	return m, nil
+}
+
+// normalize returns the value as it would be decoded from JSON.
+func normalize(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return value
+	}
+	return v
+}
+
+// claimStrings returns the strings in a space-delimited string claim or an
+// array claim.
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		s := make([]string, 0, len(v))
+		for _, elem := range v {
+			if str, ok := elem.(string); ok {
+				s = append(s, str)
+			}
+		}
+		return s
+	}
+	return nil
+}
+
+// containsAll returns true if have contains every element of want.
+func containsAll(have, want []string) bool {
+	for _, w := range want {
+		if !containsAny(have, []string{w}) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAny returns true if have contains at least one element of want.
+func containsAny(have, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
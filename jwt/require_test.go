@@ -0,0 +1,98 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smxlong/kit/boolean"
+)
+
+// serveWithClaims serves a request with the given claims in its context
+// through the given middleware, and returns the response status code.
+func serveWithClaims(claims gojwt.Claims, mw func(http.Handler) http.Handler) int {
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest("GET", "/tenants/acme", nil)
+	req.SetPathValue("tenant", "acme")
+	if claims != nil {
+		req = req.WithContext(context.WithValue(req.Context(), ContextKeyClaims, claims))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func Test_That_Require_Rejects_A_Request_Without_Claims(t *testing.T) {
+	handler := RequireScope("read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+}
+
+func Test_That_RequireScope_Checks_Space_Delimited_And_Array_Scopes(t *testing.T) {
+	assert.Equal(t, http.StatusNoContent, serveWithClaims(gojwt.MapClaims{"scope": "read write"}, RequireScope("read", "write")))
+	assert.Equal(t, http.StatusNoContent, serveWithClaims(gojwt.MapClaims{"scp": []interface{}{"read", "write"}}, RequireScope("write")))
+	assert.Equal(t, http.StatusForbidden, serveWithClaims(gojwt.MapClaims{"scope": "read"}, RequireScope("read", "write")))
+	assert.Equal(t, http.StatusForbidden, serveWithClaims(&gojwt.RegisteredClaims{Subject: "test"}, RequireScope("read")))
+}
+
+func Test_That_RequireScope_Responds_With_An_Insufficient_Scope_Challenge(t *testing.T) {
+	handler := RequireScope("write")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ContextKeyClaims, gojwt.MapClaims{"scope": "read"}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, `Bearer error="insufficient_scope"`, rec.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, "{\"error\":\"forbidden\"}\n", rec.Body.String())
+}
+
+func Test_That_RequireAnyRole_Checks_Roles(t *testing.T) {
+	assert.Equal(t, http.StatusNoContent, serveWithClaims(gojwt.MapClaims{"roles": []interface{}{"editor"}}, RequireAnyRole("admin", "editor")))
+	assert.Equal(t, http.StatusNoContent, serveWithClaims(gojwt.MapClaims{"roles": "viewer admin"}, RequireAnyRole("admin")))
+	assert.Equal(t, http.StatusForbidden, serveWithClaims(gojwt.MapClaims{"roles": []interface{}{"viewer"}}, RequireAnyRole("admin", "editor")))
+}
+
+func Test_That_RequireClaim_Checks_Nested_Claims(t *testing.T) {
+	type customClaims struct {
+		gojwt.RegisteredClaims
+		Tenant struct {
+			ID   string `json:"id"`
+			Tier int    `json:"tier"`
+		} `json:"tenant"`
+	}
+	claims := &customClaims{}
+	claims.Tenant.ID = "acme"
+	claims.Tenant.Tier = 2
+
+	assert.Equal(t, http.StatusNoContent, serveWithClaims(claims, RequireClaim("tenant.id", "acme")))
+	assert.Equal(t, http.StatusNoContent, serveWithClaims(claims, RequireClaim("tenant.tier", 2)))
+	assert.Equal(t, http.StatusForbidden, serveWithClaims(claims, RequireClaim("tenant.id", "other")))
+	assert.Equal(t, http.StatusForbidden, serveWithClaims(claims, RequireClaim("tenant.missing", "acme")))
+	assert.Equal(t, http.StatusNoContent, serveWithClaims(gojwt.MapClaims{"groups": []interface{}{"a", "b"}}, RequireClaim("groups", "b")))
+}
+
+func Test_That_Require_Composes_With_Boolean_Predicates(t *testing.T) {
+	sameTenant := func(r *http.Request) bool {
+		tenant, ok := LookupClaim(r.Context(), "tenant")
+		return ok && tenant == r.PathValue("tenant")
+	}
+	policy := Require(boolean.Or(
+		HasAnyRole("admin"),
+		boolean.And(HasAnyRole("editor"), sameTenant),
+	))
+
+	assert.Equal(t, http.StatusNoContent, serveWithClaims(gojwt.MapClaims{"roles": "admin", "tenant": "other"}, policy))
+	assert.Equal(t, http.StatusNoContent, serveWithClaims(gojwt.MapClaims{"roles": "editor", "tenant": "acme"}, policy))
+	assert.Equal(t, http.StatusForbidden, serveWithClaims(gojwt.MapClaims{"roles": "editor", "tenant": "other"}, policy))
+	assert.Equal(t, http.StatusForbidden, serveWithClaims(gojwt.MapClaims{"roles": "viewer", "tenant": "acme"}, policy))
+}
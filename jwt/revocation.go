@@ -0,0 +1,271 @@
+package jwt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrTokenRevoked is returned when a token has been revoked.
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// Revocation is implemented by token denylists. It is checked after a token's
+// signature and claims have been validated. See WithRevocation.
+type Revocation interface {
+	// Revoked returns true if the token with the given claims has been
+	// revoked.
+	Revoked(ctx context.Context, claims gojwt.Claims) (bool, error)
+}
+
+// WithRevocation sets the Revocation checked for each token. Revoked tokens
+// are rejected with ErrTokenRevoked.
+func WithRevocation(revocation Revocation) Option {
+	return func(m *Middleware) {
+		m.revocation = revocation
+	}
+}
+
+// MemoryRevocation is an in-memory Revocation. Tokens are revoked by jti, or
+// by subject and issue time to revoke all of a subject's sessions. Each
+// revocation is forgotten once it expires, which should be no earlier than
+// the expiry of the tokens it revokes. Expired revocations are swept from
+// memory periodically when revocations are added.
+type MemoryRevocation struct {
+	mu        sync.Mutex
+	tokens    map[string]time.Time
+	subjects  map[string]subjectRevocation
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// revocationSweepInterval is the minimum time between sweeps of expired
+// revocations.
+const revocationSweepInterval = time.Minute
+
+// subjectRevocation revokes a subject's tokens issued before a time.
+type subjectRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+// NewMemoryRevocation returns an empty MemoryRevocation.
+func NewMemoryRevocation() *MemoryRevocation {
+	return &MemoryRevocation{
+		tokens:   map[string]time.Time{},
+		subjects: map[string]subjectRevocation{},
+		now:      time.Now,
+	}
+}
+
+// RevokeToken revokes the token with the given jti until expiresAt.
+func (r *MemoryRevocation) RevokeToken(jti string, expiresAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep()
+	if expiresAt.After(r.tokens[jti]) {
+		r.tokens[jti] = expiresAt
+	}
+}
+
+// RevokeSubject revokes all tokens for the given subject that were issued
+// before issuedBefore, until expiresAt. Tokens for the subject that have no
+// iat claim are also revoked.
+func (r *MemoryRevocation) RevokeSubject(sub string, issuedBefore, expiresAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep()
+	current := r.subjects[sub]
+	if issuedBefore.After(current.issuedBefore) {
+		current.issuedBefore = issuedBefore
+	}
+	if expiresAt.After(current.expiresAt) {
+		current.expiresAt = expiresAt
+	}
+	r.subjects[sub] = current
+}
+
+// Revoked implements Revocation.
+func (r *MemoryRevocation) Revoked(_ context.Context, claims gojwt.Claims) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, err := claimsMap(claims)
+	if err != nil {
+		return false, err
+	}
+	now := r.now()
+	if jti, ok := m["jti"].(string); ok && jti != "" {
+		if expiresAt, ok := r.tokens[jti]; ok && now.Before(expiresAt) {
+			return true, nil
+		}
+	}
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return false, err
+	}
+	if revocation, ok := r.subjects[sub]; ok && sub != "" && now.Before(revocation.expiresAt) {
+		iat, err := claims.GetIssuedAt()
+		if err != nil {
+			return false, err
+		}
+		if iat == nil || iat.Before(revocation.issuedBefore) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sweep forgets expired revocations, if the sweep interval has passed since
+// the last sweep. The caller must hold r.mu.
+func (r *MemoryRevocation) sweep() {
+	now := r.now()
+	if now.Sub(r.lastSweep) < revocationSweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for jti, expiresAt := range r.tokens {
+		if !now.Before(expiresAt) {
+			delete(r.tokens, jti)
+		}
+	}
+	for sub, revocation := range r.subjects {
+		if !now.Before(revocation.expiresAt) {
+			delete(r.subjects, sub)
+		}
+	}
+}
+
+// FileRevocation is a MemoryRevocation that records revocations in a file, so
+// that they survive restarts.
+type FileRevocation struct {
+	*MemoryRevocation
+	path string
+	mu   sync.Mutex
+}
+
+// revocationRecord is a revocation as recorded in a file.
+type revocationRecord struct {
+	TokenID      string    `json:"jti,omitempty"`
+	Subject      string    `json:"sub,omitempty"`
+	IssuedBefore time.Time `json:"issued_before"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// OpenFileRevocation returns a FileRevocation that records revocations in the
+// file at path, loading any unexpired revocations already recorded there. The
+// file is created if it does not exist.
+func OpenFileRevocation(path string) (*FileRevocation, error) {
+	r := &FileRevocation{
+		MemoryRevocation: NewMemoryRevocation(),
+		path:             path,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RevokeToken revokes the token with the given jti until expiresAt, and
+// records the revocation.
+func (r *FileRevocation) RevokeToken(jti string, expiresAt time.Time) error {
+	if err := r.append(revocationRecord{TokenID: jti, ExpiresAt: expiresAt}); err != nil {
+		return err
+	}
+	r.MemoryRevocation.RevokeToken(jti, expiresAt)
+	return nil
+}
+
+// RevokeSubject revokes all tokens for the given subject that were issued
+// before issuedBefore, until expiresAt, and records the revocation.
+func (r *FileRevocation) RevokeSubject(sub string, issuedBefore, expiresAt time.Time) error {
+	if err := r.append(revocationRecord{Subject: sub, IssuedBefore: issuedBefore, ExpiresAt: expiresAt}); err != nil {
+		return err
+	}
+	r.MemoryRevocation.RevokeSubject(sub, issuedBefore, expiresAt)
+	return nil
+}
+
+// load reads the recorded revocations, then rewrites the file without the
+// expired ones. If the file does not exist, it is created empty.
+func (r *FileRevocation) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.Open(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r.rewrite(nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	now := r.now()
+	var records []revocationRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record revocationRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return err
+		}
+		if !now.Before(record.ExpiresAt) {
+			continue
+		}
+		records = append(records, record)
+		if record.TokenID != "" {
+			r.MemoryRevocation.RevokeToken(record.TokenID, record.ExpiresAt)
+		} else {
+			r.MemoryRevocation.RevokeSubject(record.Subject, record.IssuedBefore, record.ExpiresAt)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return r.rewrite(records)
+}
+
+// rewrite atomically replaces the file with the given records. The caller
+// must hold r.mu.
+func (r *FileRevocation) rewrite(records []revocationRecord) error {
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	enc := json.NewEncoder(tmp)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// append appends a record to the file.
+func (r *FileRevocation) append(record revocationRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(record); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,120 @@
+package jwt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_MemoryRevocation_Revokes_Tokens_By_Jti_Until_They_Expire(t *testing.T) {
+	now := time.Now()
+	r := NewMemoryRevocation()
+	r.now = func() time.Time { return now }
+	r.RevokeToken("abc", now.Add(time.Hour))
+
+	revoked, err := r.Revoked(context.Background(), &gojwt.RegisteredClaims{ID: "abc"})
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = r.Revoked(context.Background(), gojwt.MapClaims{"jti": "def"})
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	now = now.Add(time.Hour)
+	revoked, err = r.Revoked(context.Background(), &gojwt.RegisteredClaims{ID: "abc"})
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func Test_That_MemoryRevocation_Revokes_A_Subjects_Tokens_Issued_Before_A_Time(t *testing.T) {
+	now := time.Now()
+	r := NewMemoryRevocation()
+	r.now = func() time.Time { return now }
+	r.RevokeSubject("jdoe", now, now.Add(time.Hour))
+
+	for _, tc := range []struct {
+		claims  *gojwt.RegisteredClaims
+		revoked bool
+	}{
+		{&gojwt.RegisteredClaims{Subject: "jdoe", IssuedAt: gojwt.NewNumericDate(now.Add(-time.Minute))}, true},
+		{&gojwt.RegisteredClaims{Subject: "jdoe"}, true},
+		{&gojwt.RegisteredClaims{Subject: "jdoe", IssuedAt: gojwt.NewNumericDate(now.Add(time.Minute))}, false},
+		{&gojwt.RegisteredClaims{Subject: "other", IssuedAt: gojwt.NewNumericDate(now.Add(-time.Minute))}, false},
+	} {
+		revoked, err := r.Revoked(context.Background(), tc.claims)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.revoked, revoked)
+	}
+}
+
+func Test_That_FileRevocation_Survives_A_Restart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.jsonl")
+	r, err := OpenFileRevocation(path)
+	assert.NoError(t, err)
+	assert.NoError(t, r.RevokeToken("abc", time.Now().Add(time.Hour)))
+	assert.NoError(t, r.RevokeToken("old", time.Now().Add(-time.Hour)))
+	assert.NoError(t, r.RevokeSubject("jdoe", time.Now(), time.Now().Add(time.Hour)))
+
+	r, err = OpenFileRevocation(path)
+	assert.NoError(t, err)
+	revoked, err := r.Revoked(context.Background(), &gojwt.RegisteredClaims{ID: "abc"})
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = r.Revoked(context.Background(), &gojwt.RegisteredClaims{Subject: "jdoe"})
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	assert.Len(t, r.tokens, 1, "expired revocations should not be loaded")
+}
+
+func Test_That_MemoryRevocation_Sweeps_Expired_Revocations_When_Revoking(t *testing.T) {
+	now := time.Now()
+	r := NewMemoryRevocation()
+	r.now = func() time.Time { return now }
+	r.RevokeToken("abc", now.Add(time.Minute))
+	r.RevokeSubject("jdoe", now, now.Add(time.Minute))
+
+	now = now.Add(time.Minute)
+	revoked, err := r.Revoked(context.Background(), &gojwt.RegisteredClaims{ID: "abc", Subject: "jdoe"})
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	assert.Len(t, r.tokens, 1, "Revoked should not sweep")
+
+	r.RevokeToken("def", now.Add(time.Hour))
+	assert.Equal(t, map[string]time.Time{"def": now.Add(time.Hour)}, r.tokens)
+	assert.Empty(t, r.subjects)
+}
+
+func Test_That_OpenFileRevocation_Creates_A_Missing_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.jsonl")
+	_, err := OpenFileRevocation(path)
+	assert.NoError(t, err)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	_, err = OpenFileRevocation(filepath.Join(t.TempDir(), "missing", "revoked.jsonl"))
+	assert.Error(t, err)
+}
+
+func Test_That_WithRevocation_Rejects_Revoked_Tokens(t *testing.T) {
+	r := NewMemoryRevocation()
+	r.RevokeToken("abc", time.Now().Add(time.Hour))
+	middleware := NewMiddleware(
+		WithKey([]byte("secret")),
+		WithRevocation(r),
+	)
+
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{ID: "abc"}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(token)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	token, err = gojwt.NewWithClaims(gojwt.SigningMethodHS256, &gojwt.RegisteredClaims{ID: "def"}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(token)
+	assert.NoError(t, err)
+}
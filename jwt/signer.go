@@ -0,0 +1,99 @@
+package jwt
+
+import (
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Signer issues tokens signed with the active key of a KeyRing.
+type Signer struct {
+	keys     *KeyRing
+	issuer   string
+	audience []string
+	lifetime time.Duration
+	now      func() time.Time
+}
+
+// SignerOption is an option for a Signer.
+type SignerOption func(*Signer)
+
+// WithSignerIssuer sets the iss claim of tokens that do not have one.
+func WithSignerIssuer(issuer string) SignerOption {
+	return func(s *Signer) {
+		s.issuer = issuer
+	}
+}
+
+// WithSignerAudience sets the aud claim of tokens that do not have one.
+func WithSignerAudience(audience ...string) SignerOption {
+	return func(s *Signer) {
+		s.audience = audience
+	}
+}
+
+// WithSignerLifetime sets the lifetime of tokens that do not have an exp
+// claim. The default is one hour. A lifetime of zero means such tokens do not
+// expire.
+func WithSignerLifetime(lifetime time.Duration) SignerOption {
+	return func(s *Signer) {
+		s.lifetime = lifetime
+	}
+}
+
+// NewSigner returns a Signer that signs tokens with the active key of the
+// given KeyRing. To sign with a single static key, add it to a KeyRing
+// created without a generator.
+func NewSigner(keys *KeyRing, opts ...SignerOption) *Signer {
+	s := &Signer{
+		keys:     keys,
+		lifetime: time.Hour,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Sign returns a signed token with the given claims. The iat and jti claims
+// are set if missing, as are the exp, iss and aud claims if the Signer has
+// defaults for them. The kid header is set to the ID of the signing key.
+func (s *Signer) Sign(claims gojwt.Claims) (string, error) {
+	key, err := s.keys.Active()
+	if err != nil {
+		return "", err
+	}
+	m, err := claimsMap(claims)
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	setDefault(m, "iat", now.Unix())
+	if s.lifetime > 0 {
+		setDefault(m, "exp", now.Add(s.lifetime).Unix())
+	}
+	if s.issuer != "" {
+		setDefault(m, "iss", s.issuer)
+	}
+	if len(s.audience) > 0 {
+		setDefault(m, "aud", s.audience)
+	}
+	if _, ok := m["jti"]; !ok {
+		jti, err := randomID()
+		if err != nil {
+			return "", err
+		}
+		m["jti"] = jti
+	}
+	tok := gojwt.NewWithClaims(key.Method, gojwt.MapClaims(m))
+	tok.Header["kid"] = key.ID
+	return tok.SignedString(key.Key)
+}
+
+// setDefault sets m[name] to value if m has no entry for name.
+func setDefault(m map[string]interface{}, name string, value interface{}) {
+	if _, ok := m[name]; !ok {
+		m[name] = value
+	}
+}
@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_Signer_Sets_Default_Claims_And_Kid(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	kr := NewKeyRing(WithKeyRingAlgorithm("EdDSA"))
+	signer := NewSigner(kr,
+		WithSignerIssuer("https://issuer.example.com"),
+		WithSignerAudience("https://api.example.com"),
+		WithSignerLifetime(15*time.Minute),
+	)
+	signer.now = func() time.Time { return now }
+
+	token, err := signer.Sign(&gojwt.RegisteredClaims{Subject: "jdoe"})
+	assert.NoError(t, err)
+
+	key, err := kr.Active()
+	assert.NoError(t, err)
+	claims := gojwt.MapClaims{}
+	tok, err := gojwt.ParseWithClaims(token, claims, kr.Keyfunc, gojwt.WithTimeFunc(func() time.Time { return now }))
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, tok.Header["kid"])
+	assert.Equal(t, "EdDSA", tok.Header["alg"])
+	assert.Equal(t, "jdoe", claims["sub"])
+	assert.Equal(t, "https://issuer.example.com", claims["iss"])
+	assert.Equal(t, []interface{}{"https://api.example.com"}, claims["aud"])
+	assert.Equal(t, float64(now.Unix()), claims["iat"])
+	assert.Equal(t, float64(now.Add(15*time.Minute).Unix()), claims["exp"])
+	assert.NotEmpty(t, claims["jti"])
+}
+
+func Test_That_Signer_Keeps_Claims_That_Are_Already_Set(t *testing.T) {
+	kr := NewKeyRing(WithKeyRingAlgorithm("HS256"))
+	signer := NewSigner(kr, WithSignerIssuer("default"))
+	exp := time.Now().Add(time.Minute).Truncate(time.Second)
+
+	token, err := signer.Sign(gojwt.MapClaims{"iss": "custom", "jti": "abc", "exp": exp.Unix()})
+	assert.NoError(t, err)
+
+	claims := gojwt.MapClaims{}
+	_, err = gojwt.ParseWithClaims(token, claims, kr.Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, "custom", claims["iss"])
+	assert.Equal(t, "abc", claims["jti"])
+	assert.Equal(t, float64(exp.Unix()), claims["exp"])
+}
+
+func Test_That_Signer_Returns_An_Error_Without_A_Key(t *testing.T) {
+	_, err := NewSigner(NewKeyRing()).Sign(&gojwt.RegisteredClaims{})
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+}
+
+// unencodableClaims is a test claims type that cannot be encoded as JSON.
+type unencodableClaims struct {
+	gojwt.RegisteredClaims
+}
+
+// MarshalJSON fails.
+func (unencodableClaims) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("cannot encode claims")
+}
+
+func Test_That_Signer_Returns_An_Error_When_The_Claims_Cannot_Be_Encoded(t *testing.T) {
+	signer := NewSigner(NewKeyRing(WithKeyRingAlgorithm("HS256")))
+	token, err := signer.Sign(&unencodableClaims{})
+	assert.ErrorContains(t, err, "cannot encode claims")
+	assert.Empty(t, token)
+}
@@ -0,0 +1,75 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrUnknownIssuer is returned when a token's issuer is not one of the
+	// tenants of a Middleware.
+	ErrUnknownIssuer = errors.New("unknown issuer")
+)
+
+// Tenant is an issuer of tokens accepted by a Middleware created WithTenants.
+type Tenant struct {
+	// ID identifies the tenant. It is stored in the request context under
+	// ContextKeyTenant.
+	ID string
+	// Issuer is the tenant's iss claim.
+	Issuer string
+	// Key is the key used to validate the tenant's tokens, in any of the
+	// forms accepted by WithKey, such as a KeySet.
+	Key interface{}
+	// Audiences are the audiences accepted in the tenant's tokens. If empty,
+	// the audiences set WithAudience and WithAudiences are used.
+	Audiences []string
+	// NewClaims returns a new claims instance for the tenant's tokens. If nil,
+	// the function set WithNewClaims is used.
+	NewClaims func() gojwt.Claims
+}
+
+// WithTenants sets the tenants whose tokens are accepted. Each token is
+// validated against the tenant whose Issuer matches the token's iss claim,
+// which is read before the token is verified. Tokens from any other issuer are
+// rejected. The tenant's ID is stored in the request context under
+// ContextKeyTenant.
+func WithTenants(tenants ...Tenant) Option {
+	return func(m *Middleware) {
+		if m.tenants == nil {
+			m.tenants = map[string]*Tenant{}
+		}
+		for _, tenant := range tenants {
+			tenant := tenant
+			m.tenants[tenant.Issuer] = &tenant
+		}
+	}
+}
+
+// TenantFrom returns the ID of the tenant stored in the context by a
+// Middleware created WithTenants.
+func TenantFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ContextKeyTenant).(string)
+	return id, ok
+}
+
+// tenantFor returns the tenant that issued the token, based on the token's
+// unverified iss claim.
+func (m *Middleware) tenantFor(token string) (*Tenant, error) {
+	claims := gojwt.MapClaims{}
+	if _, _, err := gojwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return nil, err
+	}
+	issuer, err := claims.GetIssuer()
+	if err != nil {
+		return nil, err
+	}
+	tenant, ok := m.tenants[issuer]
+	if !ok {
+		return nil, fmt.Errorf("%w: %w: %w %q", gojwt.ErrTokenInvalidClaims, gojwt.ErrTokenInvalidIssuer, ErrUnknownIssuer, issuer)
+	}
+	return tenant, nil
+}
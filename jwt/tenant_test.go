@@ -0,0 +1,85 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_WithTenants_Validates_Each_Token_Against_Its_Issuers_Tenant(t *testing.T) {
+	acme := NewKeyRing(WithKeyRingAlgorithm("RS256"))
+	globex := NewKeyRing(WithKeyRingAlgorithm("ES256"))
+	middleware := NewMiddleware(
+		WithTenants(
+			Tenant{ID: "acme", Issuer: "https://acme.example.com", Key: acme, Audiences: []string{"api"}},
+			Tenant{
+				ID:        "globex",
+				Issuer:    "https://globex.example.com",
+				Key:       globex,
+				NewClaims: func() gojwt.Claims { return &testClaims{} },
+			},
+		),
+		WithRequired(true),
+	)
+
+	serve := func(token string) (int, string, gojwt.Claims) {
+		var tenant string
+		var claims gojwt.Claims
+		handler := middleware.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, _ = TenantFrom(r.Context())
+			claims, _ = ClaimsFrom[gojwt.Claims](r.Context())
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code, tenant, claims
+	}
+
+	token, err := NewSigner(acme, WithSignerIssuer("https://acme.example.com"), WithSignerAudience("api")).Sign(&gojwt.RegisteredClaims{})
+	assert.NoError(t, err)
+	code, tenant, claims := serve(token)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "acme", tenant)
+	assert.IsType(t, &gojwt.RegisteredClaims{}, claims)
+
+	token, err = NewSigner(globex, WithSignerIssuer("https://globex.example.com")).Sign(&testClaims{Name: "test"})
+	assert.NoError(t, err)
+	code, tenant, claims = serve(token)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "globex", tenant)
+	assert.Equal(t, "test", claims.(*testClaims).Name)
+
+	// Signed by globex, but claiming to be from acme.
+	token, err = NewSigner(globex, WithSignerIssuer("https://acme.example.com"), WithSignerAudience("api")).Sign(&gojwt.RegisteredClaims{})
+	assert.NoError(t, err)
+	code, _, _ = serve(token)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Audience not accepted by acme.
+	token, err = NewSigner(acme, WithSignerIssuer("https://acme.example.com"), WithSignerAudience("other")).Sign(&gojwt.RegisteredClaims{})
+	assert.NoError(t, err)
+	code, _, _ = serve(token)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func Test_That_WithTenants_Rejects_Unknown_Issuers(t *testing.T) {
+	keys := NewKeyRing(WithKeyRingAlgorithm("HS256"))
+	middleware := NewMiddleware(
+		WithTenants(Tenant{ID: "acme", Issuer: "https://acme.example.com", Key: keys}),
+	)
+
+	token, err := NewSigner(keys, WithSignerIssuer("https://unknown.example.com")).Sign(&gojwt.RegisteredClaims{})
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(token)
+	assert.ErrorIs(t, err, ErrUnknownIssuer)
+	assert.ErrorIs(t, err, gojwt.ErrTokenInvalidIssuer)
+
+	token, err = NewSigner(keys).Sign(&gojwt.RegisteredClaims{})
+	assert.NoError(t, err)
+	_, err = middleware.parseClaims(token)
+	assert.ErrorIs(t, err, ErrUnknownIssuer)
+}
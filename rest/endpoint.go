@@ -3,8 +3,10 @@ package rest
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"reflect"
 )
 
 // Request is a REST request.
@@ -17,6 +19,8 @@ type Response interface{}
 type ErrorResponse struct {
 	// Error is the error.
 	Error string `json:"error"`
+	// Errors are the field errors, if any.
+	Errors []FieldError `json:"errors,omitempty"`
 }
 
 // Implementation is the implementation of a REST endpoint.
@@ -28,15 +32,34 @@ type Handler struct {
 	NewRequest func() Request
 	// Handle handles the request.
 	Handle Implementation
+
+	// Summary is a short summary of the operation, for API documentation.
+	Summary string
+	// Description is a longer description of the operation, for API
+	// documentation.
+	Description string
+	// Tags group the operation in API documentation.
+	Tags []string
+	// Errors are the errors the handler may return, documented as responses
+	// in API documentation.
+	Errors []*Error
+
+	// response is the type of the handler's responses, if known.
+	response reflect.Type
 }
 
 // Endpoint is the specification of a REST endpoint.
 type Endpoint struct {
 	// Method is the HTTP method of the endpoint.
 	Method map[string]Handler
+	// ErrorFormat is the format of error responses. The default is
+	// ErrorFormatLegacy.
+	ErrorFormat ErrorFormat
 }
 
-// Validate is implemented by Requests that can be validated.
+// Validate is implemented by Requests that can be validated. Validate is
+// called after the request's validate struct tags are checked; see
+// ValidateStruct.
 type Validate interface {
 	// Validate validates the request.
 	Validate() error
@@ -52,12 +75,16 @@ type StatusCode interface {
 func (e *Endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	handler, ok := e.Method[r.Method]
 	if !ok {
-		errorResponse(w, ErrNotSupported)
+		e.ErrorFormat.writeError(w, r, ErrNotSupported)
 		return
 	}
 	req := handler.NewRequest()
 	if err := decode(r, req); err != nil {
-		errorResponse(w, err)
+		e.ErrorFormat.writeError(w, r, err)
+		return
+	}
+	if err := ValidateStruct(req); err != nil {
+		e.ErrorFormat.writeError(w, r, err)
 		return
 	}
 	if v, ok := req.(Validate); ok {
@@ -65,39 +92,59 @@ func (e *Endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			if _, ok := err.(StatusCode); !ok {
 				err = ErrBadRequest.WithCause(err)
 			}
-			errorResponse(w, err)
+			e.ErrorFormat.writeError(w, r, err)
 			return
 		}
 	}
 	ctx := r.Context()
 	res := handler.Handle(ctx, req)
 	if err, ok := res.(error); ok {
-		errorResponse(w, err)
+		e.ErrorFormat.writeError(w, r, err)
 		return
 	}
 	statusCode := statusCodeOrDefault(http.StatusOK, res)
 	encode(w, res, statusCode)
 }
 
+// WriteError writes err to w as an error response, in the same format used by
+// Endpoint. The status code is taken from err if it implements StatusCode, and
+// defaults to 500 otherwise. This is useful for middleware that needs to reject
+// a request before it reaches an Endpoint.
+func WriteError(w http.ResponseWriter, err error) {
+	errorResponse(w, err)
+}
+
 // errorResponse sends an error response.
 func errorResponse(w http.ResponseWriter, err error) {
 	statusCode := statusCodeOrDefault(http.StatusInternalServerError, err)
-	e := &ErrorResponse{err.Error()}
+	e := &ErrorResponse{Error: err.Error()}
+	var re *Error
+	if errors.As(err, &re) {
+		e.Errors = re.fieldErrors
+	}
 	encode(w, e, statusCode)
 }
 
-// decode decodes the given http.Request to the given Request.
+// decode decodes the given http.Request to the given Request. The body is
+// decoded as JSON unless the Request has parameter fields and no other fields,
+// and parameter fields are then bound from the path, query and headers.
 func decode(r *http.Request, req Request) error {
-	if err := validateHeaders(r); err != nil {
-		return err
+	rt := requestTypeOf(req)
+	if rt.err != nil {
+		return rt.err
 	}
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-		if err == io.EOF {
-			return ErrEmptyBody
+	if rt.body {
+		if err := validateHeaders(r); err != nil {
+			return err
+		}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			if err == io.EOF {
+				return ErrEmptyBody
+			}
+			return ErrBadRequest.WithCause(err)
 		}
-		return ErrBadRequest.WithCause(err)
 	}
-	return nil
+	return bindParams(r, req, rt.params)
 }
 
 // validateHeaders validates the headers of the given http.Request.
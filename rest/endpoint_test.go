@@ -100,6 +100,15 @@ func Test_That_errorResponse_Returns_A_JSON_Response(t *testing.T) {
 	assert.Equal(t, "{\"error\":\"test\"}\n", rec.Body.String())
 }
 
+func Test_That_WriteError_Returns_A_JSON_Response(t *testing.T) {
+	t.Parallel()
+	rec := httptest.NewRecorder()
+	WriteError(rec, ErrUnauthorized)
+	assert.Equal(t, 401, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"error\":\"unauthorized\"}\n", rec.Body.String())
+}
+
 //////////////////////////////////////////////////////////////////////////////
 // decode tests
 
@@ -4,9 +4,27 @@ import "net/http"
 
 // Error is an error from an endpoint.
 type Error struct {
-	message    string
-	statusCode int
-	cause      error
+	message     string
+	statusCode  int
+	cause       error
+	typeURI     string
+	code        string
+	fieldErrors []FieldError
+}
+
+// FieldError is an error in one field of a request.
+type FieldError struct {
+	// Field is the path of the field, such as "items[0].name".
+	Field string `json:"field"`
+	// Code is a machine-readable code for the error, such as "required".
+	Code string `json:"code,omitempty"`
+	// Message describes the error.
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
 }
 
 // NewError returns a new Error.
@@ -47,11 +65,46 @@ func (e *Error) Cause() error {
 
 // WithCause returns a new Error with the given cause.
 func (e *Error) WithCause(cause error) *Error {
-	return &Error{
-		message:    e.message,
-		statusCode: e.statusCode,
-		cause:      cause,
-	}
+	c := *e
+	c.cause = cause
+	return &c
+}
+
+// Type returns the problem type URI of the error, or "" if it has none.
+func (e *Error) Type() string {
+	return e.typeURI
+}
+
+// WithType returns a new Error with the given problem type URI, which
+// identifies the kind of problem in problem+json responses.
+func (e *Error) WithType(typeURI string) *Error {
+	c := *e
+	c.typeURI = typeURI
+	return &c
+}
+
+// Code returns the machine-readable code of the error, or "" if it has none.
+func (e *Error) Code() string {
+	return e.code
+}
+
+// WithCode returns a new Error with the given machine-readable code.
+func (e *Error) WithCode(code string) *Error {
+	c := *e
+	c.code = code
+	return &c
+}
+
+// FieldErrors returns the field errors of the error.
+func (e *Error) FieldErrors() []FieldError {
+	return e.fieldErrors
+}
+
+// WithFieldErrors returns a new Error with the given field errors added.
+func (e *Error) WithFieldErrors(fieldErrors ...FieldError) *Error {
+	c := *e
+	c.fieldErrors = append(append([]FieldError(nil), e.fieldErrors...), fieldErrors...)
+	return &c
 }
 
 var (
@@ -54,3 +54,19 @@ func Test_That_Error_Implements_The_Cause_Method(t *testing.T) {
 	err := NewError("test", 1).WithCause(cause)
 	assert.Equal(t, cause, err.Cause())
 }
+
+func Test_That_Error_With_Methods_Return_Copies(t *testing.T) {
+	t.Parallel()
+	fe := FieldError{Field: "name", Code: "required", Message: "is required"}
+	base := NewError("test", 1)
+	err := base.WithType("https://example.com/problems/test").WithCode("TEST").WithFieldErrors(fe).WithCause(errors.New("cause"))
+	assert.Equal(t, "https://example.com/problems/test", err.Type())
+	assert.Equal(t, "TEST", err.Code())
+	assert.Equal(t, []FieldError{fe}, err.FieldErrors())
+	assert.Equal(t, "cause", err.Cause().Error())
+	assert.True(t, errors.Is(err, base))
+	assert.Empty(t, base.Type())
+	assert.Empty(t, base.Code())
+	assert.Empty(t, base.FieldErrors())
+	assert.Equal(t, "name: is required", fe.Error())
+}
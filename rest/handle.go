@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"context"
+	"reflect"
+)
+
+// Handle returns a Handler for a function with typed request and response.
+// The Handler's requests are new *Req values, and are decoded and validated as
+// for any Handler: *Req may implement Validate, and *Resp may implement
+// StatusCode.
+//
+// If fn returns an error, it is written as an error response. Otherwise the
+// response is written; a nil response is written as JSON null.
+//
+// Handle panics if Req has a parameter field of an unsupported type, or if
+// Req, or a struct type it validates recursively, has an invalid validate tag.
+func Handle[Req, Resp any](fn func(context.Context, *Req) (*Resp, error)) Handler {
+	if err := requestTypeOf(new(Req)).err; err != nil {
+		panic(err)
+	}
+	if err := checkValidateTags(reflect.TypeOf((*Req)(nil)), map[reflect.Type]bool{}); err != nil {
+		panic(err)
+	}
+	return Handler{
+		NewRequest: func() Request {
+			return new(Req)
+		},
+		Handle: func(ctx context.Context, req Request) Response {
+			resp, err := fn(ctx, req.(*Req))
+			if err != nil {
+				return err
+			}
+			if resp == nil {
+				return nil
+			}
+			return resp
+		},
+		response: reflect.TypeOf((*Resp)(nil)),
+	}
+}
@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveTestRequest serves a POST request with a JSON body.
+func serveTestRequest(ep *Endpoint, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/test", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = io.NopCloser(bytes.NewBufferString(body))
+	ep.ServeHTTP(rec, req)
+	return rec
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// Handle tests
+
+func Test_That_Handle_Passes_The_Typed_Request(t *testing.T) {
+	t.Parallel()
+	ep := &Endpoint{
+		Method: map[string]Handler{
+			"POST": Handle(func(ctx context.Context, req *TRequest) (*TResponse, error) {
+				return &TResponse{req.Message[:4], req.Number - 1}, nil
+			}),
+		},
+	}
+	rec := serveTestRequest(ep, `{"message":"testq","number":2}`)
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "{\"response_message\":\"test\",\"response_number\":1}\n", rec.Body.String())
+}
+
+func Test_That_Handle_Validates_The_Request(t *testing.T) {
+	t.Parallel()
+	called := false
+	ep := &Endpoint{
+		Method: map[string]Handler{
+			"POST": Handle(func(ctx context.Context, req *TRequest) (*TResponse, error) {
+				called = true
+				return &TResponse{}, nil
+			}),
+		},
+	}
+	rec := serveTestRequest(ep, `{"message":"test","number":1}`)
+	assert.Equal(t, 400, rec.Code)
+	assert.False(t, called)
+}
+
+func Test_That_Handle_Uses_The_Response_StatusCode(t *testing.T) {
+	t.Parallel()
+	ep := &Endpoint{
+		Method: map[string]Handler{
+			"POST": Handle(func(ctx context.Context, req *TRequest) (*TResponseWithStatusCode, error) {
+				return &TResponseWithStatusCode{"created", 1, 201}, nil
+			}),
+		},
+	}
+	rec := serveTestRequest(ep, `{"message":"testq","number":2}`)
+	assert.Equal(t, 201, rec.Code)
+	assert.Equal(t, "{\"response_message\":\"created\",\"response_number\":1}\n", rec.Body.String())
+}
+
+func Test_That_Handle_Writes_Errors(t *testing.T) {
+	t.Parallel()
+	ep := &Endpoint{
+		Method: map[string]Handler{
+			"POST": Handle(func(ctx context.Context, req *TRequest) (*TResponse, error) {
+				return &TResponse{"ignored", 1}, ErrConflict
+			}),
+		},
+	}
+	rec := serveTestRequest(ep, `{"message":"testq","number":2}`)
+	assert.Equal(t, 409, rec.Code)
+	assert.Equal(t, "{\"error\":\"conflict\"}\n", rec.Body.String())
+
+	ep.Method["POST"] = Handle(func(ctx context.Context, req *TRequest) (*TResponse, error) {
+		return nil, errors.New("test")
+	})
+	rec = serveTestRequest(ep, `{"message":"testq","number":2}`)
+	assert.Equal(t, 500, rec.Code)
+	assert.Equal(t, "{\"error\":\"test\"}\n", rec.Body.String())
+}
+
+func Test_That_Handle_Writes_A_Nil_Response_As_Null(t *testing.T) {
+	t.Parallel()
+	ep := &Endpoint{
+		Method: map[string]Handler{
+			"POST": Handle(func(ctx context.Context, req *TRequest) (*TResponseWithStatusCode, error) {
+				return nil, nil
+			}),
+		},
+	}
+	rec := serveTestRequest(ep, `{"message":"testq","number":2}`)
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "null\n", rec.Body.String())
+}
@@ -0,0 +1,591 @@
+package rest
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OpenAPIVersion is the version of the OpenAPI documents produced by API.
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPI is an OpenAPI document. Only the members produced by API are
+// included.
+type OpenAPI struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Servers    []OpenAPIServer            `json:"servers,omitempty"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components OpenAPIComponents          `json:"components"`
+}
+
+// OpenAPIInfo is the metadata of an API.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIServer is a server that hosts an API.
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIPathItem is the operations on a path, by lowercase HTTP method.
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+// OpenAPIOperation is an operation on a path.
+type OpenAPIOperation struct {
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter is a path, query or header parameter of an operation.
+type OpenAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// OpenAPIRequestBody is the request body of an operation.
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIMediaType is the schema of a request or response body.
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// OpenAPIResponse is a response of an operation.
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIComponents are the reusable schemas of a document.
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema is a JSON Schema. Only the keywords produced by API are included.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// WithSummary returns a copy of the Handler with the given summary.
+func (h Handler) WithSummary(summary string) Handler {
+	h.Summary = summary
+	return h
+}
+
+// WithDescription returns a copy of the Handler with the given description.
+func (h Handler) WithDescription(description string) Handler {
+	h.Description = description
+	return h
+}
+
+// WithTags returns a copy of the Handler with the given tags added.
+func (h Handler) WithTags(tags ...string) Handler {
+	h.Tags = append(slices.Clone(h.Tags), tags...)
+	return h
+}
+
+// WithErrors returns a copy of the Handler with the given errors added.
+func (h Handler) WithErrors(errs ...*Error) Handler {
+	h.Errors = append(slices.Clone(h.Errors), errs...)
+	return h
+}
+
+// WithResponse returns a copy of the Handler that documents its responses
+// with the type of resp. Handlers made with Handle already know the type.
+func (h Handler) WithResponse(resp Response) Handler {
+	h.response = reflect.TypeOf(resp)
+	return h
+}
+
+// API is a set of Endpoints mounted on paths, which serves them and describes
+// them in an OpenAPI 3.1 document.
+//
+// The document is generated from the Endpoints' handlers: parameters from
+// request fields tagged path, query and header; the request body from the
+// other request fields; schemas from the JSON names and validate tags of
+// fields; and error responses from the errors the Endpoint itself returns and
+// those listed in each Handler's Errors.
+type API struct {
+	info    OpenAPIInfo
+	servers []OpenAPIServer
+	mux     *http.ServeMux
+
+	mu     sync.Mutex
+	routes []apiRoute
+}
+
+// apiRoute is an Endpoint mounted on a path.
+type apiRoute struct {
+	pattern  string
+	endpoint *Endpoint
+}
+
+// APIOption is an option for an API.
+type APIOption func(*API)
+
+// WithAPIDescription sets the description of the API.
+func WithAPIDescription(description string) APIOption {
+	return func(a *API) {
+		a.info.Description = description
+	}
+}
+
+// WithAPIServers sets the URLs of the servers that host the API.
+func WithAPIServers(urls ...string) APIOption {
+	return func(a *API) {
+		for _, url := range urls {
+			a.servers = append(a.servers, OpenAPIServer{URL: url})
+		}
+	}
+}
+
+// NewAPI returns an API with the given title and version.
+func NewAPI(title, version string, opts ...APIOption) *API {
+	a := &API{
+		info: OpenAPIInfo{Title: title, Version: version},
+		mux:  http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Handle mounts an Endpoint on a path pattern, such as "/widgets/{id}". The
+// pattern is as for http.ServeMux, without a method or host.
+func (a *API) Handle(pattern string, e *Endpoint) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mux.Handle(pattern, e)
+	a.routes = append(a.routes, apiRoute{pattern: pattern, endpoint: e})
+}
+
+// ServeHTTP implements the http.Handler interface, serving the Endpoints.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+// Document returns the OpenAPI document of the API. It returns an error if a
+// request or response type has an invalid validate tag, or a request type has
+// a parameter field of an unsupported type.
+func (a *API) Document() (*OpenAPI, error) {
+	a.mu.Lock()
+	routes := slices.Clone(a.routes)
+	a.mu.Unlock()
+
+	g := newSchemaGenerator()
+	doc := &OpenAPI{
+		OpenAPI: OpenAPIVersion,
+		Info:    a.info,
+		Servers: a.servers,
+		Paths:   map[string]OpenAPIPathItem{},
+	}
+	for _, route := range routes {
+		path := openAPIPath(route.pattern)
+		item := doc.Paths[path]
+		if item == nil {
+			item = OpenAPIPathItem{}
+			doc.Paths[path] = item
+		}
+		for _, method := range slices.Sorted(maps.Keys(route.endpoint.Method)) {
+			handler := route.endpoint.Method[method]
+			item[strings.ToLower(method)] = g.operation(handler, route.endpoint.ErrorFormat)
+		}
+	}
+	if g.err != nil {
+		return nil, g.err
+	}
+	doc.Components.Schemas = g.components
+	return doc, nil
+}
+
+// WriteDocument writes the OpenAPI document of the API to w as JSON.
+func (a *API) WriteDocument(w io.Writer) error {
+	data, err := a.marshalDocument()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// marshalDocument returns the OpenAPI document of the API as indented JSON.
+func (a *API) marshalDocument() ([]byte, error) {
+	doc, err := a.Document()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// DocumentHandler returns an http.Handler that serves the OpenAPI document of
+// the API.
+func (a *API) DocumentHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			errorResponse(w, ErrNotSupported)
+			return
+		}
+		data, err := a.marshalDocument()
+		if err != nil {
+			errorResponse(w, ErrInternal.WithCause(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	})
+}
+
+// patternWildcard matches a wildcard in a ServeMux pattern.
+var patternWildcard = regexp.MustCompile(`\{([^}.]*)(\.\.\.)?\}`)
+
+// openAPIPath returns the OpenAPI path of a ServeMux pattern.
+func openAPIPath(pattern string) string {
+	path := strings.Replace(pattern, "{$}", "", 1)
+	return patternWildcard.ReplaceAllString(path, "{$1}")
+}
+
+// schemaGenerator generates schemas for Go types, collecting the schemas of
+// named struct types as components.
+type schemaGenerator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+	err        error // the first invalid validate tag, if any
+}
+
+// newSchemaGenerator returns a schemaGenerator.
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+// operation returns the operation of a Handler.
+func (g *schemaGenerator) operation(h Handler, format ErrorFormat) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Summary:     h.Summary,
+		Description: h.Description,
+		Tags:        h.Tags,
+		Responses:   map[string]*OpenAPIResponse{},
+	}
+	ok := &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+	if h.response != nil {
+		ok.Content = map[string]*OpenAPIMediaType{"application/json": {Schema: g.schema(h.response)}}
+	}
+	op.Responses[strconv.Itoa(http.StatusOK)] = ok
+
+	errs := h.Errors
+	if h.NewRequest != nil {
+		req := h.NewRequest()
+		rt := requestTypeOf(req)
+		if rt.err != nil {
+			g.fail(rt.err)
+		}
+		t := reflect.TypeOf(req)
+		for _, p := range rt.params {
+			f := t.Elem().FieldByIndex(p.index)
+			param := &OpenAPIParameter{Name: p.name, In: p.source, Schema: g.schema(f.Type)}
+			param.Required = g.applyRules(param.Schema, t.Elem(), f) || p.source == "path"
+			op.Parameters = append(op.Parameters, param)
+		}
+		if rt.body {
+			op.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content:  map[string]*OpenAPIMediaType{"application/json": {Schema: g.schema(t)}},
+			}
+			errs = append(errs, ErrBadContentType, ErrEmptyBody, ErrBadRequest)
+		}
+		if len(rt.params) > 0 {
+			errs = append(errs, ErrBadRequest)
+		}
+		// Fields tagged json:"-" have no schema, so their tags are checked
+		// here rather than by applyRules.
+		if err := checkValidateTags(t, map[reflect.Type]bool{}); err != nil {
+			g.fail(err)
+		} else if hasValidation(t, map[reflect.Type]bool{}) {
+			errs = append(errs, ErrValidation)
+		}
+		if _, ok := req.(Validate); ok {
+			errs = append(errs, ErrBadRequest)
+		}
+	}
+	for _, err := range errs {
+		status := strconv.Itoa(err.statusCode)
+		resp := op.Responses[status]
+		if resp == nil {
+			resp = &OpenAPIResponse{Content: g.errorContent(format)}
+			op.Responses[status] = resp
+		}
+		if resp.Description == "" {
+			resp.Description = err.message
+		} else if !slices.Contains(strings.Split(resp.Description, "; "), err.message) {
+			resp.Description += "; " + err.message
+		}
+	}
+	return op
+}
+
+// errorContent returns the content of error responses in the given format.
+func (g *schemaGenerator) errorContent(format ErrorFormat) map[string]*OpenAPIMediaType {
+	if format == ErrorFormatProblem {
+		// Problem marshals its extensions itself, so its schema is built here.
+		t := reflect.TypeOf(Problem{})
+		name, ok := g.names[t]
+		if !ok {
+			name = g.componentName(t)
+			g.names[t] = name
+			problem := g.structSchema(t)
+			problem.Properties["code"] = &Schema{Type: "string"}
+			problem.Properties["errors"] = &Schema{Type: "array", Items: g.schema(reflect.TypeOf(FieldError{}))}
+			g.components[name] = problem
+		}
+		schema := &Schema{Ref: "#/components/schemas/" + name}
+		return map[string]*OpenAPIMediaType{"application/problem+json": {Schema: schema}}
+	}
+	return map[string]*OpenAPIMediaType{"application/json": {Schema: g.schema(reflect.TypeOf(ErrorResponse{}))}}
+}
+
+// Types with particular schemas.
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schema returns the schema of a type.
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	}
+	if t.Kind() != reflect.Struct || t.Name() != "" {
+		if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+			return &Schema{}
+		} else if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+			return &Schema{Type: "string"}
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.componentName(t)
+			g.names[t] = name
+			g.components[name] = &Schema{}
+			g.components[name] = g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// structSchema returns the schema of a struct type. Fields bound to request
+// parameters are omitted.
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, f := range jsonFields(t) {
+		if isParamField(f) {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := g.schema(f.Type)
+		if g.applyRules(prop, t, f) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// applyRules adds the validate rules of a field of struct type st to its
+// schema. It returns true if the field is required.
+func (g *schemaGenerator) applyRules(s *Schema, st reflect.Type, f reflect.StructField) bool {
+	rules, err := parseRules(f.Tag.Get("validate"), f.Type)
+	if err != nil {
+		g.fail(fmt.Errorf("rest: invalid validate tag on %s.%s: %w", st, f.Name, err))
+		return false
+	}
+	t := f.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	required := false
+	for _, r := range rules {
+		switch r.name {
+		case "required":
+			required = true
+		case "min", "max":
+			bound := r.bound
+			n := int(bound)
+			switch t.Kind() {
+			case reflect.String:
+				if r.name == "min" {
+					s.MinLength = &n
+				} else {
+					s.MaxLength = &n
+				}
+			case reflect.Slice, reflect.Array, reflect.Map:
+				if r.name == "min" {
+					s.MinItems = &n
+				} else {
+					s.MaxItems = &n
+				}
+			default:
+				if r.name == "min" {
+					s.Minimum = &bound
+				} else {
+					s.Maximum = &bound
+				}
+			}
+		case "email":
+			s.Format = "email"
+		case "uuid":
+			s.Format = "uuid"
+		case "oneof":
+			for _, v := range r.oneOf {
+				if t.Kind() == reflect.String {
+					s.Enum = append(s.Enum, v)
+				} else if n, err := strconv.ParseFloat(v, 64); err == nil {
+					s.Enum = append(s.Enum, n)
+				}
+			}
+		}
+	}
+	return required
+}
+
+// fail records err, unless an error was already recorded.
+func (g *schemaGenerator) fail(err error) {
+	if g.err == nil {
+		g.err = err
+	}
+}
+
+// componentName returns a unique component name for a named type.
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '.' || r == '_' || r == '-' || ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') {
+				return r
+			}
+			return '_'
+		}, s)
+	}
+	name := clean(t.Name())
+	if _, taken := g.components[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	name = clean(pkg + "." + t.Name())
+	for i := 2; ; i++ {
+		if _, taken := g.components[name]; !taken {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", clean(pkg+"."+t.Name()), i)
+	}
+}
+
+// isParamField returns true if a field is bound to a request parameter.
+func isParamField(f reflect.StructField) bool {
+	for _, source := range paramSources {
+		if _, ok := f.Tag.Lookup(source); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasValidation returns true if values of type t have validate rules. The
+// validate tags of t must be valid; see checkValidateTags.
+func hasValidation(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] || t == timeType {
+		return false
+	}
+	seen[t] = true
+	for _, f := range validatedTypeOf(t).fields {
+		if len(f.rules) > 0 || hasValidation(t.FieldByIndex(f.index).Type, seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TWidget is a test response.
+type TWidget struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Parts   []TWidgetPart     `json:"parts,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Created time.Time         `json:"created"`
+	secret  string
+}
+
+// TWidgetPart is a test nested struct.
+type TWidgetPart struct {
+	Name     string `json:"name" validate:"required"`
+	Quantity int    `json:"quantity" validate:"min=1,max=10"`
+}
+
+// TGetWidgetRequest is a test request with only parameters.
+type TGetWidgetRequest struct {
+	ID     string   `path:"id"`
+	Fields []string `query:"field"`
+	Tenant string   `header:"X-Tenant" validate:"required,uuid"`
+}
+
+// TCreateWidgetRequest is a test request with a body.
+type TCreateWidgetRequest struct {
+	Name  string        `json:"name" validate:"required,max=50"`
+	Kind  string        `json:"kind" validate:"oneof=small large"`
+	Parts []TWidgetPart `json:"parts" validate:"max=5"`
+	Note  string        `json:"-"`
+}
+
+// newTestAPI returns an API with widget endpoints.
+func newTestAPI() *API {
+	api := NewAPI("Widgets", "1.0.0", WithAPIDescription("Widget API."), WithAPIServers("https://api.example.com"))
+	api.Handle("/widgets", &Endpoint{
+		Method: map[string]Handler{
+			"POST": Handle(func(ctx context.Context, req *TCreateWidgetRequest) (*TWidget, error) {
+				return &TWidget{ID: "w1", Name: req.Name}, nil
+			}).WithSummary("Create a widget").WithTags("widgets").WithErrors(ErrConflict),
+		},
+		ErrorFormat: ErrorFormatProblem,
+	})
+	api.Handle("/widgets/{id}", &Endpoint{
+		Method: map[string]Handler{
+			"GET": Handle(func(ctx context.Context, req *TGetWidgetRequest) (*TWidget, error) {
+				return &TWidget{ID: req.ID}, nil
+			}).WithSummary("Get a widget").WithTags("widgets").WithErrors(ErrNotFound),
+		},
+	})
+	return api
+}
+
+// documentJSON returns the document of an API as generic JSON.
+func documentJSON(t *testing.T, api *API) map[string]interface{} {
+	var buf bytes.Buffer
+	assert.NoError(t, api.WriteDocument(&buf))
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	return doc
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// API tests
+
+func Test_That_API_Serves_Its_Endpoints(t *testing.T) {
+	t.Parallel()
+	api := newTestAPI()
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest("GET", "/widgets/w1", nil))
+	assert.Equal(t, 422, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/widgets/w1", nil)
+	req.Header.Set("X-Tenant", "0b9e2a4c-6f3d-4e0b-9a51-3b2f9c1d7e88")
+	api.ServeHTTP(rec, req)
+	assert.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"w1"`)
+}
+
+func Test_That_API_Document_Describes_Parameters(t *testing.T) {
+	t.Parallel()
+	doc := documentJSON(t, newTestAPI())
+	assert.Equal(t, "3.1.0", doc["openapi"])
+	assert.Equal(t, map[string]interface{}{"title": "Widgets", "version": "1.0.0", "description": "Widget API."}, doc["info"])
+
+	op := doc["paths"].(map[string]interface{})["/widgets/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "Get a widget", op["summary"])
+	assert.Equal(t, []interface{}{"widgets"}, op["tags"])
+	assert.Nil(t, op["requestBody"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "id", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+		map[string]interface{}{"name": "field", "in": "query", "schema": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}},
+		map[string]interface{}{"name": "X-Tenant", "in": "header", "required": true, "schema": map[string]interface{}{"type": "string", "format": "uuid"}},
+	}, op["parameters"])
+
+	responses := op["responses"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"description": "OK",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/TWidget"}},
+		},
+	}, responses["200"])
+	assert.Equal(t, "bad request", responses["400"].(map[string]interface{})["description"])
+	assert.Equal(t, "not found", responses["404"].(map[string]interface{})["description"])
+	assert.Equal(t, "validation failed", responses["422"].(map[string]interface{})["description"])
+	assert.Equal(t, map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"}},
+	}, responses["404"].(map[string]interface{})["content"])
+}
+
+func Test_That_API_Document_Describes_Request_Bodies(t *testing.T) {
+	t.Parallel()
+	doc := documentJSON(t, newTestAPI())
+	op := doc["paths"].(map[string]interface{})["/widgets"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/TCreateWidgetRequest"}},
+		},
+	}, op["requestBody"])
+
+	responses := op["responses"].(map[string]interface{})
+	assert.Equal(t, "empty body; bad request", responses["400"].(map[string]interface{})["description"])
+	assert.Equal(t, "conflict", responses["409"].(map[string]interface{})["description"])
+	assert.Equal(t, "bad content type", responses["415"].(map[string]interface{})["description"])
+	assert.Equal(t, map[string]interface{}{
+		"application/problem+json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Problem"}},
+	}, responses["409"].(map[string]interface{})["content"])
+}
+
+func Test_That_API_Document_Has_Component_Schemas(t *testing.T) {
+	t.Parallel()
+	schemas := documentJSON(t, newTestAPI())["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "maxLength": 50},
+			"kind": {"type": "string", "enum": ["small", "large"]},
+			"parts": {"type": "array", "items": {"$ref": "#/components/schemas/TWidgetPart"}, "maxItems": 5}
+		},
+		"required": ["name"]
+	}`, mustJSON(t, schemas["TCreateWidgetRequest"]))
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"quantity": {"type": "integer", "format": "int64", "minimum": 1, "maximum": 10}
+		},
+		"required": ["name"]
+	}`, mustJSON(t, schemas["TWidgetPart"]))
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"id": {"type": "string"},
+			"name": {"type": "string"},
+			"parts": {"type": "array", "items": {"$ref": "#/components/schemas/TWidgetPart"}},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"created": {"type": "string", "format": "date-time"}
+		}
+	}`, mustJSON(t, schemas["TWidget"]))
+	assert.Contains(t, schemas["Problem"].(map[string]interface{})["properties"], "errors")
+	assert.Contains(t, schemas, "FieldError")
+	assert.Contains(t, schemas, "ErrorResponse")
+}
+
+func Test_That_API_Document_Promotes_Fields_Of_Embedded_Struct_Pointers(t *testing.T) {
+	t.Parallel()
+	type embeddedWidget struct {
+		*TWidgetPart
+		ID string `json:"id"`
+	}
+	api := NewAPI("Widgets", "1.0.0")
+	api.Handle("/widgets/{id}", &Endpoint{
+		Method: map[string]Handler{
+			"GET": Handle(func(ctx context.Context, req *TGetWidgetRequest) (*embeddedWidget, error) {
+				return nil, nil
+			}),
+		},
+	})
+	schemas := documentJSON(t, api)["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"id": {"type": "string"},
+			"name": {"type": "string"},
+			"quantity": {"type": "integer", "format": "int64", "minimum": 1, "maximum": 10}
+		},
+		"required": ["name"]
+	}`, mustJSON(t, schemas["embeddedWidget"]))
+}
+
+func Test_That_API_Document_Nests_Embedded_Structs_With_A_JSON_Name(t *testing.T) {
+	t.Parallel()
+	type namedEmbed struct {
+		TWidgetPart `json:"part"`
+		ID          string `json:"id"`
+	}
+	api := NewAPI("Widgets", "1.0.0")
+	api.Handle("/widgets/{id}", &Endpoint{
+		Method: map[string]Handler{
+			"GET": Handle(func(ctx context.Context, req *TGetWidgetRequest) (*namedEmbed, error) {
+				return nil, nil
+			}),
+		},
+	})
+	schemas := documentJSON(t, api)["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"id": {"type": "string"},
+			"part": {"$ref": "#/components/schemas/TWidgetPart"}
+		}
+	}`, mustJSON(t, schemas["namedEmbed"]))
+}
+
+func Test_That_API_Document_Returns_Error_For_Invalid_Tags_And_Parameters(t *testing.T) {
+	t.Parallel()
+	type badResponse struct {
+		Name string `json:"name" validate:"omitempty"`
+	}
+	type badRequest struct {
+		Name     string `json:"name"`
+		Internal string `json:"-" validate:"gte=1"`
+	}
+	type badParamRequest struct {
+		Filter TWidgetPart `query:"filter"`
+	}
+	for _, h := range []Handler{
+		Handle(func(ctx context.Context, req *TGetWidgetRequest) (*badResponse, error) {
+			return nil, nil
+		}),
+		{
+			NewRequest: func() Request { return &badRequest{} },
+			Handle:     func(ctx context.Context, req Request) Response { return nil },
+		},
+		{
+			NewRequest: func() Request { return &badParamRequest{} },
+			Handle:     func(ctx context.Context, req Request) Response { return nil },
+		},
+	} {
+		api := NewAPI("Widgets", "1.0.0")
+		api.Handle("/widgets", &Endpoint{Method: map[string]Handler{"POST": h}})
+		doc, err := api.Document()
+		assert.Nil(t, doc)
+		assert.ErrorContains(t, err, "rest: ")
+		assert.Error(t, api.WriteDocument(&bytes.Buffer{}))
+		rec := httptest.NewRecorder()
+		api.DocumentHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+		assert.Equal(t, 500, rec.Code)
+	}
+}
+
+func Test_That_API_DocumentHandler_Serves_The_Document(t *testing.T) {
+	t.Parallel()
+	api := newTestAPI()
+	rec := httptest.NewRecorder()
+	api.DocumentHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var buf bytes.Buffer
+	assert.NoError(t, api.WriteDocument(&buf))
+	assert.Equal(t, buf.String(), rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	api.DocumentHandler().ServeHTTP(rec, httptest.NewRequest("POST", "/openapi.json", nil))
+	assert.Equal(t, 405, rec.Code)
+}
+
+func Test_That_openAPIPath_Converts_ServeMux_Patterns(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "/widgets/{id}", openAPIPath("/widgets/{id}"))
+	assert.Equal(t, "/files/{path}", openAPIPath("/files/{path...}"))
+	assert.Equal(t, "/", openAPIPath("/{$}"))
+}
+
+// mustJSON returns v as JSON.
+func mustJSON(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return string(data)
+}
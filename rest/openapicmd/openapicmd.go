@@ -0,0 +1,39 @@
+// Package openapicmd provides a command that exports the OpenAPI document of a
+// rest.API. It is separate from rest so that rest does not depend on cobra.
+package openapicmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/smxlong/kit/rest"
+)
+
+// Command returns an "openapi" command that writes the OpenAPI document of
+// the API to stdout, or to the file given with --out. Add it to a program's
+// commands to export the document at build time.
+func Command(api *rest.API) *cobra.Command {
+	var out string
+	cmd := &cobra.Command{
+		Use:          "openapi",
+		Short:        "Write the OpenAPI document of the API.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if out == "" {
+				return api.WriteDocument(cmd.OutOrStdout())
+			}
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			if err := api.WriteDocument(f); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		},
+	}
+	cmd.Flags().StringVarP(&out, "out", "o", "", "The file to write the document to. Defaults to stdout.")
+	return cmd
+}
@@ -0,0 +1,60 @@
+package openapicmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smxlong/kit/rest"
+)
+
+// TWidget is a test response.
+type TWidget struct {
+	ID string `json:"id"`
+}
+
+// newTestAPI returns an API with one endpoint.
+func newTestAPI() *rest.API {
+	api := rest.NewAPI("Widgets", "1.0.0")
+	api.Handle("/widgets/{id}", &rest.Endpoint{
+		Method: map[string]rest.Handler{
+			"GET": rest.Handle(func(ctx context.Context, req *struct {
+				ID string `path:"id"`
+			}) (*TWidget, error) {
+				return &TWidget{ID: req.ID}, nil
+			}),
+		},
+	})
+	return api
+}
+
+func Test_That_Command_Writes_The_Document(t *testing.T) {
+	t.Parallel()
+	api := newTestAPI()
+	var out bytes.Buffer
+	cmd := Command(api)
+	cmd.SetOut(&out)
+	cmd.SetArgs(nil)
+	assert.NoError(t, cmd.Execute())
+	var want bytes.Buffer
+	assert.NoError(t, api.WriteDocument(&want))
+	assert.Equal(t, want.String(), out.String())
+}
+
+func Test_That_Command_Writes_The_Document_To_A_File(t *testing.T) {
+	t.Parallel()
+	api := newTestAPI()
+	path := filepath.Join(t.TempDir(), "openapi.json")
+	cmd := Command(api)
+	cmd.SetArgs([]string{"--out", path})
+	assert.NoError(t, cmd.Execute())
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var want bytes.Buffer
+	assert.NoError(t, api.WriteDocument(&want))
+	assert.Equal(t, want.String(), string(data))
+}
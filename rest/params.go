@@ -0,0 +1,295 @@
+package rest
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request fields are bound to request parameters with struct tags:
+//
+//	type GetWidgetRequest struct {
+//		ID     string        `path:"id"`
+//		Limit  int           `query:"limit"`
+//		Tags   []string      `query:"tag"`
+//		Tenant string        `header:"X-Tenant"`
+//		Wait   time.Duration `query:"wait"`
+//	}
+//
+// Path parameters are taken from http.Request.PathValue, so the Endpoint must
+// be registered with a pattern that names them, such as "/widgets/{id}".
+//
+// Supported field types are strings, bools, integers, floats, time.Duration,
+// time.Time (RFC 3339), types implementing encoding.TextUnmarshaler, and
+// pointers to and slices of these. A slice collects every value of a query
+// parameter or header. A parameter that is absent leaves its field as the zero
+// value. A parameter field of any other type is a programming error: Handle
+// panics, API.Document returns an error, and requests fail with
+// 500 Internal Server Error.
+
+// paramSources are the struct tags that bind a field to a request parameter.
+var paramSources = []string{"path", "query", "header"}
+
+// paramField is a request field bound to a request parameter.
+type paramField struct {
+	index  []int  // field index, for reflect.Value.FieldByIndex
+	source string // "path", "query" or "header"
+	name   string // parameter name
+}
+
+// requestType describes how a request type is decoded.
+type requestType struct {
+	params []paramField
+	// body is true if the request is decoded from the body: it has fields that
+	// are not bound to parameters, or it has no parameter fields at all.
+	body bool
+	// err is the error in a parameter field, if any.
+	err error
+}
+
+// requestTypes caches requestType by reflect.Type.
+var requestTypes sync.Map
+
+// requestTypeOf returns the requestType of a request.
+func requestTypeOf(req Request) *requestType {
+	t := reflect.TypeOf(req)
+	if cached, ok := requestTypes.Load(t); ok {
+		return cached.(*requestType)
+	}
+	rt := &requestType{body: true}
+	if t != nil && t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct {
+		rt.params, rt.body, rt.err = paramFields(t.Elem())
+	}
+	requestTypes.Store(t, rt)
+	return rt
+}
+
+// paramFields returns the parameter fields of a struct type, and whether it
+// is decoded from the body. It returns an error for a parameter field of an
+// unsupported type.
+func paramFields(t reflect.Type) ([]paramField, bool, error) {
+	var params []paramField
+	bodyFields := 0
+	for _, f := range jsonFields(t) {
+		bound := false
+		for _, source := range paramSources {
+			if name, ok := f.Tag.Lookup(source); ok {
+				if name == "" {
+					name = f.Name
+				}
+				if !isParamType(f.Type) {
+					return nil, false, fmt.Errorf("rest: unsupported type %s for %s parameter %s.%s", f.Type, source, t, f.Name)
+				}
+				params = append(params, paramField{index: f.Index, source: source, name: name})
+				bound = true
+				break
+			}
+		}
+		if !bound && f.Tag.Get("json") != "-" {
+			bodyFields++
+		}
+	}
+	return params, bodyFields > 0 || len(params) == 0, nil
+}
+
+// isParamType returns true if a field of type t can be bound to a parameter.
+func isParamType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && !implementsTextUnmarshaler(t) {
+		t = t.Elem()
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if implementsTextUnmarshaler(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// jsonFields returns the exported fields of a struct type as encoding/json
+// sees them. The fields of an embedded struct, or pointer to a struct, are
+// promoted unless it has a JSON name, in which case it is an ordinary field.
+func jsonFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || isFlattened(f) || !promotedByJSON(t, f.Index) {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// isFlattened returns true if the fields of f are promoted to its parent by
+// encoding/json: it is an embedded struct, or pointer to a struct, without a
+// JSON name.
+func isFlattened(f reflect.StructField) bool {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return f.Anonymous && isStructOrPointer(f.Type) && name == ""
+}
+
+// promotedByJSON returns true if every embedded struct on the path to the
+// field with the given index is flattened.
+func promotedByJSON(t reflect.Type, index []int) bool {
+	for i := 1; i < len(index); i++ {
+		if !isFlattened(t.FieldByIndex(index[:i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// isStructOrPointer returns true if t is a struct or a pointer to a struct.
+func isStructOrPointer(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// bindParams sets the parameter fields of req from r. Parameter fields are
+// always reset first, so that they cannot be set from the body. Nil embedded
+// struct pointers are allocated as needed to set a parameter field.
+func bindParams(r *http.Request, req Request, params []paramField) error {
+	v := reflect.ValueOf(req).Elem()
+	for _, p := range params {
+		var values []string
+		switch p.source {
+		case "path":
+			if value := r.PathValue(p.name); value != "" {
+				values = []string{value}
+			}
+		case "query":
+			values = r.URL.Query()[p.name]
+		case "header":
+			values = r.Header.Values(p.name)
+		}
+		if len(values) == 0 {
+			// A field in a nil embedded struct is already zero.
+			if field, err := v.FieldByIndexErr(p.index); err == nil {
+				field.SetZero()
+			}
+			continue
+		}
+		field, err := allocFieldByIndex(v, p.index)
+		if err != nil {
+			return err
+		}
+		if err := setField(field, values); err != nil {
+			return ErrBadRequest.WithCause(fmt.Errorf("%s parameter %q: %w", p.source, p.name, err))
+		}
+	}
+	return nil
+}
+
+// allocFieldByIndex returns the field of v with the given index, allocating
+// nil embedded struct pointers along the way.
+func allocFieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// setField sets a field from the values of a parameter.
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && !implementsTextUnmarshaler(field.Type()) {
+		s := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(s)
+		return nil
+	}
+	return setValue(field, values[len(values)-1])
+}
+
+// durationType and timeType are the reflect.Types of time.Duration and
+// time.Time.
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// setValue parses a value into v.
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// implementsTextUnmarshaler returns true if pointers to t implement
+// encoding.TextUnmarshaler.
+func implementsTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
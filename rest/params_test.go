@@ -0,0 +1,239 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TParamRequest is a test request with only parameter fields.
+type TParamRequest struct {
+	ID      string        `path:"id"`
+	Limit   int           `query:"limit"`
+	Offset  *uint         `query:"offset"`
+	Ratio   float64       `query:"ratio"`
+	Verbose bool          `query:"verbose"`
+	Tags    []string      `query:"tag"`
+	Wait    time.Duration `query:"wait"`
+	Since   time.Time     `query:"since"`
+	IP      net.IP        `query:"ip"`
+	Tenant  string        `header:"X-Tenant"`
+}
+
+// TMixedRequest is a test request with parameter and body fields.
+type TMixedRequest struct {
+	ID      string `path:"id" json:"id"`
+	Message string `json:"message"`
+}
+
+// TCommonParams is a test struct with parameter fields, for embedding.
+type TCommonParams struct {
+	Limit int `query:"limit"`
+}
+
+// TEmbeddedParamRequest is a test request that embeds parameter fields by
+// pointer.
+type TEmbeddedParamRequest struct {
+	*TCommonParams
+	ID string `path:"id"`
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// requestTypeOf tests
+
+func Test_That_requestTypeOf_Finds_Parameter_And_Body_Fields(t *testing.T) {
+	t.Parallel()
+	rt := requestTypeOf(&TParamRequest{})
+	assert.False(t, rt.body)
+	assert.Len(t, rt.params, 10)
+	assert.Equal(t, paramField{index: []int{9}, source: "header", name: "X-Tenant"}, rt.params[9])
+
+	rt = requestTypeOf(&TMixedRequest{})
+	assert.True(t, rt.body)
+	assert.Len(t, rt.params, 1)
+
+	rt = requestTypeOf(&TEmptyRequest{})
+	assert.True(t, rt.body)
+	assert.Empty(t, rt.params)
+}
+
+func Test_That_requestTypeOf_Does_Not_Count_Embedded_Struct_Pointers_As_Body_Fields(t *testing.T) {
+	t.Parallel()
+	rt := requestTypeOf(&TEmbeddedParamRequest{})
+	assert.False(t, rt.body)
+	assert.Equal(t, []paramField{
+		{index: []int{0, 0}, source: "query", name: "limit"},
+		{index: []int{1}, source: "path", name: "id"},
+	}, rt.params)
+}
+
+func Test_That_requestTypeOf_Does_Not_Bind_Fields_Of_Embedded_Structs_With_A_JSON_Name(t *testing.T) {
+	t.Parallel()
+	type request struct {
+		*TCommonParams `json:"common"`
+		ID             string `path:"id"`
+	}
+	rt := requestTypeOf(&request{})
+	assert.True(t, rt.body)
+	assert.Equal(t, []paramField{{index: []int{1}, source: "path", name: "id"}}, rt.params)
+}
+
+func Test_That_requestTypeOf_Returns_Error_For_Unsupported_Parameter_Types(t *testing.T) {
+	t.Parallel()
+	type structParam struct {
+		Filter TCommonParams `query:"filter"`
+	}
+	type mapParam struct {
+		Labels map[string]string `header:"X-Labels"`
+	}
+	type nestedSliceParam struct {
+		IDs [][]string `query:"id"`
+	}
+	for _, req := range []Request{&structParam{}, &mapParam{}, &nestedSliceParam{}} {
+		assert.ErrorContains(t, requestTypeOf(req).err, "unsupported type", "%T", req)
+	}
+	assert.NoError(t, requestTypeOf(&TParamRequest{}).err)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// decode tests
+
+func Test_That_decode_Binds_Parameters_Without_A_Body(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest("GET", "/widgets/w1?limit=10&offset=5&ratio=0.5&verbose=true&tag=a&tag=b&wait=1m&since=2024-01-02T03:04:05Z&ip=10.0.0.1", nil)
+	req.SetPathValue("id", "w1")
+	req.Header.Set("X-Tenant", "acme")
+	out := &TParamRequest{}
+	assert.NoError(t, decode(req, out))
+	assert.Equal(t, "w1", out.ID)
+	assert.Equal(t, 10, out.Limit)
+	if assert.NotNil(t, out.Offset) {
+		assert.Equal(t, uint(5), *out.Offset)
+	}
+	assert.Equal(t, 0.5, out.Ratio)
+	assert.True(t, out.Verbose)
+	assert.Equal(t, []string{"a", "b"}, out.Tags)
+	assert.Equal(t, time.Minute, out.Wait)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), out.Since)
+	assert.Equal(t, "10.0.0.1", out.IP.String())
+	assert.Equal(t, "acme", out.Tenant)
+}
+
+func Test_That_decode_Leaves_Absent_Parameters_Zero(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest("GET", "/widgets", nil)
+	out := &TParamRequest{}
+	assert.NoError(t, decode(req, out))
+	assert.Equal(t, &TParamRequest{}, out)
+}
+
+func Test_That_decode_Allocates_Nil_Embedded_Structs_For_Parameters(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest("GET", "/widgets/w1?limit=10", nil)
+	req.SetPathValue("id", "w1")
+	out := &TEmbeddedParamRequest{}
+	assert.NoError(t, decode(req, out))
+	assert.Equal(t, &TEmbeddedParamRequest{TCommonParams: &TCommonParams{Limit: 10}, ID: "w1"}, out)
+
+	req = httptest.NewRequest("GET", "/widgets/w1", nil)
+	req.SetPathValue("id", "w1")
+	out = &TEmbeddedParamRequest{}
+	assert.NoError(t, decode(req, out))
+	assert.Equal(t, &TEmbeddedParamRequest{ID: "w1"}, out)
+}
+
+func Test_That_decode_Returns_Error_For_Invalid_Parameter(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest("GET", "/widgets?limit=ten", nil)
+	err := decode(req, &TParamRequest{})
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	assert.ErrorContains(t, err.(*Error).Cause(), `query parameter "limit"`)
+}
+
+func Test_That_decode_Returns_Server_Error_For_Unsupported_Parameter_Type(t *testing.T) {
+	t.Parallel()
+	type structParam struct {
+		Filter TCommonParams `query:"filter"`
+	}
+	req := httptest.NewRequest("GET", "/widgets?filter=x", nil)
+	err := decode(req, &structParam{})
+	assert.ErrorContains(t, err, "unsupported type")
+	assert.False(t, errors.Is(err, ErrBadRequest))
+}
+
+func Test_That_decode_Binds_Parameters_Over_The_Body(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest("PUT", "/widgets/w1", nil)
+	req.SetPathValue("id", "w1")
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = io.NopCloser(bytes.NewBufferString(`{"id":"w2","message":"test"}`))
+	out := &TMixedRequest{}
+	assert.NoError(t, decode(req, out))
+	assert.Equal(t, "w1", out.ID)
+	assert.Equal(t, "test", out.Message)
+}
+
+func Test_That_decode_Requires_A_Body_For_Requests_With_Body_Fields(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest("PUT", "/widgets/w1", nil)
+	assert.Equal(t, ErrBadContentType, decode(req, &TMixedRequest{}))
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// ServeHTTP tests
+
+func Test_That_ServeHTTP_Binds_Path_Values_From_The_Pattern(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle("/widgets/{id}", &Endpoint{
+		Method: map[string]Handler{
+			"GET": {
+				NewRequest: func() Request {
+					return &TParamRequest{}
+				},
+				Handle: func(ctx context.Context, req Request) Response {
+					r := req.(*TParamRequest)
+					return &TResponse{r.ID, r.Limit}
+				},
+			},
+		},
+	})
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/widgets/w1?limit=3", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "{\"response_message\":\"w1\",\"response_number\":3}\n", rec.Body.String())
+}
+
+func Test_That_ServeHTTP_Binds_Parameters_Of_Embedded_Struct_Pointers(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle("/widgets/{id}", &Endpoint{
+		Method: map[string]Handler{
+			"GET": Handle(func(ctx context.Context, req *TEmbeddedParamRequest) (*TResponse, error) {
+				return &TResponse{req.ID, req.Limit}, nil
+			}),
+		},
+	})
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/widgets/w1?limit=3", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "{\"response_message\":\"w1\",\"response_number\":3}\n", rec.Body.String())
+}
+
+func Test_That_Handle_Panics_For_Unsupported_Parameter_Types(t *testing.T) {
+	t.Parallel()
+	type structParam struct {
+		Filter TCommonParams `query:"filter"`
+	}
+	assert.PanicsWithError(t, "rest: unsupported type rest.TCommonParams for query parameter rest.structParam.Filter", func() {
+		Handle(func(ctx context.Context, req *structParam) (*TResponse, error) { return nil, nil })
+	})
+}
@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorFormat is the format of error responses.
+type ErrorFormat int
+
+const (
+	// ErrorFormatLegacy writes errors as {"error": "message"}, with the field
+	// errors of an *Error as "errors". This is the default.
+	ErrorFormatLegacy ErrorFormat = iota
+	// ErrorFormatProblem writes errors as RFC 9457 problem details, with
+	// content type application/problem+json.
+	ErrorFormatProblem
+)
+
+// Problem is an RFC 9457 problem details object.
+type Problem struct {
+	// Type is a URI that identifies the problem type. "about:blank" means the
+	// problem has no semantics beyond the HTTP status code.
+	Type string `json:"type"`
+	// Title is a short summary of the problem type.
+	Title string `json:"title,omitempty"`
+	// Status is the HTTP status code.
+	Status int `json:"status,omitempty"`
+	// Detail is an explanation of this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI that identifies this occurrence of the problem.
+	Instance string `json:"instance,omitempty"`
+	// Extensions are additional members of the problem object. Extensions
+	// with the same name as a member above are ignored.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler, writing extensions as top-level
+// members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	data, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+	m := map[string]interface{}{}
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// NewProblem returns the problem details for an error. The status is taken
+// from err if it implements StatusCode, or from the *Error it wraps, and
+// defaults to 500 otherwise.
+//
+// If err is or wraps an *Error, its type URI is the problem type, and its
+// code and field errors are the "code" and "errors" extensions. For errors
+// without a type URI, the type is "about:blank" and the title is the status
+// text. The detail is the error message, followed by the cause for client
+// errors; the causes of server errors are not disclosed. Server errors that
+// are not *Error have no detail, since their messages are internal.
+func NewProblem(err error, instance string) *Problem {
+	var e *Error
+	wrapped := errors.As(err, &e)
+	status := http.StatusInternalServerError
+	if wrapped {
+		status = e.statusCode
+	}
+	status = statusCodeOrDefault(status, err)
+	p := &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: instance,
+	}
+	if !wrapped {
+		if status < http.StatusInternalServerError {
+			p.Detail = err.Error()
+		}
+		return p
+	}
+	if e.typeURI != "" {
+		p.Type = e.typeURI
+		p.Title = e.message
+	}
+	p.Detail = e.message
+	if e.cause != nil && status < http.StatusInternalServerError {
+		p.Detail += ": " + e.cause.Error()
+	}
+	p.Extensions = map[string]interface{}{}
+	if e.code != "" {
+		p.Extensions["code"] = e.code
+	}
+	if len(e.fieldErrors) > 0 {
+		p.Extensions["errors"] = e.fieldErrors
+	}
+	return p
+}
+
+// WriteProblem writes err to w as an RFC 9457 problem details response, with
+// the request path as the instance. See NewProblem.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := NewProblem(err, r.URL.Path)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// writeError writes err to w in the given format.
+func (f ErrorFormat) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if f == ErrorFormatProblem {
+		WriteProblem(w, r, err)
+		return
+	}
+	errorResponse(w, err)
+}
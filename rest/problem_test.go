@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// Problem tests
+
+func Test_That_Problem_Marshals_Extensions_As_Members(t *testing.T) {
+	t.Parallel()
+	p := &Problem{
+		Type:   "about:blank",
+		Status: 400,
+		Extensions: map[string]interface{}{
+			"code":   "bad",
+			"status": "ignored",
+		},
+	}
+	data, err := json.Marshal(p)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"about:blank","status":400,"code":"bad"}`, string(data))
+
+	data, err = json.Marshal(&Problem{Type: "about:blank"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"about:blank"}`, string(data))
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// NewProblem tests
+
+func Test_That_NewProblem_Describes_An_Error(t *testing.T) {
+	t.Parallel()
+	p := NewProblem(ErrBadRequest.WithCause(errors.New("number must be even")), "/test")
+	assert.Equal(t, &Problem{
+		Type:       "about:blank",
+		Title:      "Bad Request",
+		Status:     400,
+		Detail:     "bad request: number must be even",
+		Instance:   "/test",
+		Extensions: map[string]interface{}{},
+	}, p)
+}
+
+func Test_That_NewProblem_Includes_Type_Code_And_Field_Errors(t *testing.T) {
+	t.Parallel()
+	err := NewError("out of stock", 409).
+		WithType("https://example.com/problems/out-of-stock").
+		WithCode("OUT_OF_STOCK").
+		WithFieldErrors(FieldError{Field: "items[0].sku", Code: "unavailable", Message: "is unavailable"})
+	p := NewProblem(fmt.Errorf("ordering: %w", err), "/orders")
+	assert.Equal(t, "https://example.com/problems/out-of-stock", p.Type)
+	assert.Equal(t, "out of stock", p.Title)
+	assert.Equal(t, 409, p.Status)
+	assert.Equal(t, "OUT_OF_STOCK", p.Extensions["code"])
+	assert.Equal(t, err.FieldErrors(), p.Extensions["errors"])
+}
+
+func Test_That_NewProblem_Does_Not_Disclose_Server_Error_Causes(t *testing.T) {
+	t.Parallel()
+	p := NewProblem(ErrInternal.WithCause(errors.New("database password rejected")), "/test")
+	assert.Equal(t, 500, p.Status)
+	assert.Equal(t, "internal error", p.Detail)
+
+	p = NewProblem(fmt.Errorf("loading widget: %w", errors.New("dial tcp 10.0.0.5:5432: connection refused")), "/test")
+	assert.Equal(t, 500, p.Status)
+	assert.Equal(t, "Internal Server Error", p.Title)
+	assert.Empty(t, p.Detail)
+	data, err := json.Marshal(p)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "10.0.0.5")
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// ServeHTTP tests
+
+func Test_That_ServeHTTP_Writes_Problems_With_ErrorFormatProblem(t *testing.T) {
+	t.Parallel()
+	ep := &Endpoint{
+		Method: map[string]Handler{
+			"POST": Handle(func(ctx context.Context, req *TRequest) (*TResponse, error) {
+				return nil, ErrNotFound.WithCode("WIDGET_NOT_FOUND")
+			}),
+		},
+		ErrorFormat: ErrorFormatProblem,
+	}
+	rec := serveTestRequest(ep, `{"message":"testq","number":2}`)
+	assert.Equal(t, 404, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","instance":"/test","code":"WIDGET_NOT_FOUND"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	ep.ServeHTTP(rec, httptest.NewRequest("GET", "/test", nil))
+	assert.Equal(t, 405, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+}
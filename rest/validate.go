@@ -0,0 +1,325 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrValidation is returned when a request fails validation. Its field
+// errors describe each violation.
+var ErrValidation = NewError("validation failed", http.StatusUnprocessableEntity)
+
+// Request fields are validated with the validate struct tag, which holds a
+// comma-separated list of rules:
+//
+//	type CreateUserRequest struct {
+//		Name  string   `json:"name" validate:"required,max=100"`
+//		Email string   `json:"email" validate:"required,email"`
+//		Role  string   `json:"role" validate:"oneof=admin member"`
+//		Tags  []string `json:"tags" validate:"max=10"`
+//	}
+//
+// The rules are:
+//
+//   - required: the value is not the zero value, or an empty slice or map.
+//   - min=N, max=N: bounds on a number, or on the length of a string (in
+//     characters), slice or map.
+//   - email: the string is an email address.
+//   - oneof=A B C: the value is one of the space-separated values.
+//   - uuid: the string is a UUID.
+//
+// The email, oneof and uuid rules accept the empty string; use required to
+// reject it. No rule but required is checked for a nil pointer. Struct fields,
+// pointers to structs, and slices of structs are validated recursively.
+
+// uuidPattern matches a UUID in its canonical form.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// rule is a parsed validation rule.
+type rule struct {
+	name  string
+	arg   string
+	bound float64  // argument of min and max
+	oneOf []string // argument of oneof
+}
+
+// validatedField is a struct field with validation rules, or one that is
+// validated recursively.
+type validatedField struct {
+	index []int
+	name  string
+	rules []rule
+}
+
+// validatedType is the validation of a struct type.
+type validatedType struct {
+	fields []validatedField
+	err    error // the error in a validate tag, if any
+}
+
+// validatedTypes caches validatedType by reflect.Type.
+var validatedTypes sync.Map
+
+// ValidateStruct validates v, a struct or pointer to a struct, against the
+// validate tags of its fields. It returns ErrValidation with a FieldError for
+// every violation, or nil if there are none. Values of other types are always
+// valid.
+func ValidateStruct(v interface{}) error {
+	var fieldErrors []FieldError
+	if err := validateValue(reflect.ValueOf(v), "", &fieldErrors); err != nil {
+		return err
+	}
+	if len(fieldErrors) > 0 {
+		return ErrValidation.WithFieldErrors(fieldErrors...)
+	}
+	return nil
+}
+
+// validateValue validates a value recursively, appending violations to
+// fieldErrors. path is the path of the value.
+func validateValue(v reflect.Value, path string, fieldErrors *[]FieldError) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		vt := validatedTypeOf(v.Type())
+		if vt.err != nil {
+			return vt.err
+		}
+		for _, f := range vt.fields {
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				// The field is in a nil embedded struct pointer, so it has
+				// the zero value.
+				fv = reflect.Zero(v.Type().FieldByIndex(f.index).Type)
+			}
+			fpath := joinPath(path, f.name)
+			for _, r := range f.rules {
+				if fe := r.check(fv); fe != nil {
+					fe.Field = fpath
+					*fieldErrors = append(*fieldErrors, *fe)
+				}
+			}
+			if err := validateValue(fv, fpath, fieldErrors); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if !containsStructs(v.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fieldErrors); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// joinPath appends a field name to a path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// containsStructs returns true if values of type t may need recursive
+// validation.
+func containsStructs(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Interface
+}
+
+// validatedTypeOf returns the validatedType of a struct type.
+func validatedTypeOf(t reflect.Type) *validatedType {
+	if cached, ok := validatedTypes.Load(t); ok {
+		return cached.(*validatedType)
+	}
+	vt := &validatedType{}
+	for _, f := range jsonFields(t) {
+		tag := f.Tag.Get("validate")
+		if tag == "" && !containsStructs(f.Type) {
+			continue
+		}
+		rules, err := parseRules(tag, f.Type)
+		if err != nil {
+			vt.err = fmt.Errorf("rest: invalid validate tag on %s.%s: %w", t, f.Name, err)
+			break
+		}
+		vt.fields = append(vt.fields, validatedField{index: f.Index, name: fieldName(f), rules: rules})
+	}
+	validatedTypes.Store(t, vt)
+	return vt
+}
+
+// checkValidateTags returns the first error in the validate tags of a type,
+// or of the struct types it validates recursively, if any. seen holds the
+// struct types already checked.
+func checkValidateTags(t reflect.Type, seen map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	vt := validatedTypeOf(t)
+	if vt.err != nil {
+		return vt.err
+	}
+	for _, f := range vt.fields {
+		if err := checkValidateTags(t.FieldByIndex(f.index).Type, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name of a field in field errors: its JSON name, or
+// the name of the parameter it is bound to, or its Go name.
+func fieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	for _, source := range paramSources {
+		if name := f.Tag.Get(source); name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// parseRules parses a validate tag for a field of type t.
+func parseRules(tag string, t reflect.Type) ([]rule, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var rules []rule
+	for _, s := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(s, "=")
+		r := rule{name: name, arg: arg}
+		switch name {
+		case "required":
+		case "min", "max":
+			bound, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", s, err)
+			}
+			r.bound = bound
+			if _, ok := measure(reflect.Zero(t)); !ok {
+				return nil, fmt.Errorf("%s: not supported for %s", name, t)
+			}
+		case "email", "uuid":
+			if t.Kind() != reflect.String {
+				return nil, fmt.Errorf("%s: not supported for %s", name, t)
+			}
+		case "oneof":
+			if k := t.Kind(); k == reflect.Slice || k == reflect.Array || k == reflect.Map {
+				return nil, fmt.Errorf("%s: not supported for %s", name, t)
+			} else if _, ok := measure(reflect.Zero(t)); !ok {
+				return nil, fmt.Errorf("%s: not supported for %s", name, t)
+			}
+			r.oneOf = strings.Fields(arg)
+			if len(r.oneOf) == 0 {
+				return nil, fmt.Errorf("%s: no values", s)
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// measure returns the quantity that min and max bound for v: a number, or the
+// length of a string, slice or map.
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// check checks a value against the rule, returning a FieldError without its
+// Field if the value violates it.
+func (r *rule) check(v reflect.Value) *FieldError {
+	if r.name == "required" {
+		if v.IsZero() || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0) {
+			return &FieldError{Code: r.name, Message: "is required"}
+		}
+		return nil
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch r.name {
+	case "min", "max":
+		n, _ := measure(v)
+		if (r.name == "min" && n >= r.bound) || (r.name == "max" && n <= r.bound) {
+			return nil
+		}
+		bound := "at least"
+		if r.name == "max" {
+			bound = "at most"
+		}
+		switch v.Kind() {
+		case reflect.String:
+			return &FieldError{Code: r.name, Message: fmt.Sprintf("must have %s %s characters", bound, r.arg)}
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return &FieldError{Code: r.name, Message: fmt.Sprintf("must have %s %s items", bound, r.arg)}
+		}
+		return &FieldError{Code: r.name, Message: fmt.Sprintf("must be %s %s", bound, r.arg)}
+	case "email":
+		if s := v.String(); s != "" {
+			if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+				return &FieldError{Code: r.name, Message: "must be an email address"}
+			}
+		}
+	case "uuid":
+		if s := v.String(); s != "" && !uuidPattern.MatchString(s) {
+			return &FieldError{Code: r.name, Message: "must be a UUID"}
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		if v.Kind() == reflect.String && s == "" {
+			return nil
+		}
+		for _, allowed := range r.oneOf {
+			if s == allowed {
+				return nil
+			}
+		}
+		return &FieldError{Code: r.name, Message: "must be one of " + strings.Join(r.oneOf, ", ")}
+	}
+	return nil
+}
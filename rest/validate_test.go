@@ -0,0 +1,214 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TAddress is a test nested struct.
+type TAddress struct {
+	City    string `json:"city" validate:"required"`
+	Country string `json:"country" validate:"oneof=CA US"`
+}
+
+// TItem is a test slice element.
+type TItem struct {
+	SKU      string `json:"sku" validate:"required,uuid"`
+	Quantity int    `json:"quantity" validate:"min=1,max=100"`
+}
+
+// TValidatedRequest is a test request with validate tags.
+type TValidatedRequest struct {
+	Name     string    `json:"name" validate:"required,max=5"`
+	Email    string    `json:"email" validate:"email"`
+	Age      *int      `json:"age" validate:"min=18"`
+	Tags     []string  `json:"tags" validate:"max=2"`
+	Tenant   string    `header:"X-Tenant" validate:"required"`
+	Address  *TAddress `json:"address" validate:"required"`
+	Items    []TItem   `json:"items"`
+	Internal string    `json:"-" validate:"required"`
+}
+
+// validTestRequest returns a TValidatedRequest that passes validation.
+func validTestRequest() *TValidatedRequest {
+	age := 30
+	return &TValidatedRequest{
+		Name:     "jdoe",
+		Email:    "jdoe@example.com",
+		Age:      &age,
+		Tags:     []string{"a"},
+		Tenant:   "acme",
+		Address:  &TAddress{City: "Toronto", Country: "CA"},
+		Items:    []TItem{{SKU: "0b9e2a4c-6f3d-4e0b-9a51-3b2f9c1d7e88", Quantity: 1}},
+		Internal: "x",
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// ValidateStruct tests
+
+func Test_That_ValidateStruct_Accepts_A_Valid_Struct(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, ValidateStruct(validTestRequest()))
+	assert.NoError(t, ValidateStruct(&TEmptyRequest{}))
+	assert.NoError(t, ValidateStruct("not a struct"))
+	assert.NoError(t, ValidateStruct(nil))
+}
+
+func Test_That_ValidateStruct_Reports_Every_Violation(t *testing.T) {
+	t.Parallel()
+	age := 17
+	req := &TValidatedRequest{
+		Name:    "johnathan",
+		Email:   "John Doe <jdoe@example.com>",
+		Age:     &age,
+		Tags:    []string{"a", "b", "c"},
+		Address: &TAddress{Country: "MX"},
+		Items: []TItem{
+			{SKU: "0b9e2a4c-6f3d-4e0b-9a51-3b2f9c1d7e88", Quantity: 1},
+			{SKU: "not-a-uuid", Quantity: 101},
+		},
+	}
+	err := ValidateStruct(req)
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.Equal(t, []FieldError{
+		{Field: "name", Code: "max", Message: "must have at most 5 characters"},
+		{Field: "email", Code: "email", Message: "must be an email address"},
+		{Field: "age", Code: "min", Message: "must be at least 18"},
+		{Field: "tags", Code: "max", Message: "must have at most 2 items"},
+		{Field: "X-Tenant", Code: "required", Message: "is required"},
+		{Field: "address.city", Code: "required", Message: "is required"},
+		{Field: "address.country", Code: "oneof", Message: "must be one of CA, US"},
+		{Field: "items[1].sku", Code: "uuid", Message: "must be a UUID"},
+		{Field: "items[1].quantity", Code: "max", Message: "must be at most 100"},
+		{Field: "Internal", Code: "required", Message: "is required"},
+	}, err.(*Error).FieldErrors())
+}
+
+func Test_That_ValidateStruct_Skips_Rules_For_Absent_Optional_Values(t *testing.T) {
+	t.Parallel()
+	req := validTestRequest()
+	req.Email = ""
+	req.Age = nil
+	req.Tags = nil
+	req.Address.Country = ""
+	assert.NoError(t, ValidateStruct(req))
+
+	req.Address = nil
+	err := ValidateStruct(req)
+	assert.Equal(t, []FieldError{{Field: "address", Code: "required", Message: "is required"}}, err.(*Error).FieldErrors())
+}
+
+func Test_That_ValidateStruct_Returns_Error_For_Invalid_Tags(t *testing.T) {
+	t.Parallel()
+	type unknownRule struct {
+		Name string `validate:"requird"`
+	}
+	type badBound struct {
+		Name string `validate:"min=x"`
+	}
+	type unsupportedType struct {
+		Count int `validate:"email"`
+	}
+	for _, v := range []interface{}{&unknownRule{}, &badBound{}, &unsupportedType{}} {
+		err := ValidateStruct(v)
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrValidation))
+	}
+}
+
+func Test_That_ValidateStruct_Treats_A_Nil_Embedded_Struct_As_Zero(t *testing.T) {
+	t.Parallel()
+	type Meta struct {
+		Owner string `json:"owner" validate:"required"`
+	}
+	type request struct {
+		*Meta
+		Name string `json:"name"`
+	}
+	err := ValidateStruct(&request{Name: "x"})
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.Equal(t, []FieldError{{Field: "owner", Code: "required", Message: "is required"}}, err.(*Error).FieldErrors())
+	assert.NoError(t, ValidateStruct(&request{Meta: &Meta{Owner: "jdoe"}}))
+}
+
+func Test_That_ValidateStruct_Validates_Embedded_Structs_With_A_JSON_Name_As_Fields(t *testing.T) {
+	t.Parallel()
+	type request struct {
+		TAddress `json:"address"`
+	}
+	err := ValidateStruct(&request{})
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.Equal(t, []FieldError{{Field: "address.city", Code: "required", Message: "is required"}}, err.(*Error).FieldErrors())
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// Handle tests
+
+func Test_That_Handle_Panics_For_Invalid_Tags(t *testing.T) {
+	t.Parallel()
+	type unknownRule struct {
+		Name string `validate:"omitempty"`
+	}
+	type nested struct {
+		Items []struct {
+			Count int `validate:"gte=1"`
+		}
+	}
+	handle := func(ctx context.Context, req *TEmptyRequest) (*TResponse, error) {
+		return nil, nil
+	}
+	assert.NotPanics(t, func() { Handle(handle) })
+	assert.PanicsWithError(t, `rest: invalid validate tag on rest.unknownRule.Name: unknown rule "omitempty"`, func() {
+		Handle(func(ctx context.Context, req *unknownRule) (*TResponse, error) { return nil, nil })
+	})
+	assert.Panics(t, func() {
+		Handle(func(ctx context.Context, req *nested) (*TResponse, error) { return nil, nil })
+	})
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// ServeHTTP tests
+
+func Test_That_ServeHTTP_Validates_Struct_Tags_Before_Validate(t *testing.T) {
+	t.Parallel()
+	type request struct {
+		TRequest
+		Code string `json:"code" validate:"required"`
+	}
+	ep := &Endpoint{
+		Method: map[string]Handler{
+			"POST": Handle(func(ctx context.Context, req *request) (*TResponse, error) {
+				return &TResponse{}, nil
+			}),
+		},
+		ErrorFormat: ErrorFormatProblem,
+	}
+	rec := serveTestRequest(ep, `{"message":"test","number":1}`)
+	assert.Equal(t, 422, rec.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"validation failed","instance":"/test","errors":[{"field":"code","code":"required","message":"is required"}]}`, rec.Body.String())
+
+	rec = serveTestRequest(ep, `{"message":"test","number":1,"code":"x"}`)
+	assert.Equal(t, 400, rec.Code)
+}
+
+func Test_That_ServeHTTP_Writes_Field_Errors_In_The_Legacy_Format(t *testing.T) {
+	t.Parallel()
+	type request struct {
+		TRequest
+		Code string `json:"code" validate:"required"`
+	}
+	ep := &Endpoint{
+		Method: map[string]Handler{
+			"POST": Handle(func(ctx context.Context, req *request) (*TResponse, error) {
+				return &TResponse{}, nil
+			}),
+		},
+	}
+	rec := serveTestRequest(ep, `{"message":"test","number":1}`)
+	assert.Equal(t, 422, rec.Code)
+	assert.JSONEq(t, `{"error":"validation failed","errors":[{"field":"code","code":"required","message":"is required"}]}`, rec.Body.String())
+}